@@ -6,12 +6,15 @@ require (
 	github.com/99designs/gqlgen v0.11.3
 	github.com/go-errors/errors v1.0.1
 	github.com/go-playground/validator/v10 v10.2.0
+	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.7.4
+	github.com/gorilla/websocket v1.4.0
 	github.com/mattn/go-colorable v0.1.6 // indirect
 	github.com/meatballhat/negroni-logrus v1.1.0
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
 	github.com/neo4j-drivers/gobolt v1.7.4 // indirect
 	github.com/neo4j/neo4j-go-driver v1.7.4
+	github.com/prometheus/client_golang v1.6.0
 	github.com/rs/cors v1.7.0
 	github.com/sirupsen/logrus v1.5.0
 	github.com/spf13/viper v1.6.3
@@ -21,4 +24,8 @@ require (
 	github.com/vektah/gqlparser v1.3.1
 	github.com/vektah/gqlparser/v2 v2.0.1
 	github.com/x-cray/logrus-prefixed-formatter v0.5.2
+	go.opentelemetry.io/otel v0.4.3
+	go.opentelemetry.io/otel/exporters/otlp v0.4.3
+	google.golang.org/grpc v1.27.1
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
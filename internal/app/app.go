@@ -1,18 +1,36 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/charlysan/goneo4jgql/internal/app/graph"
 	"github.com/charlysan/goneo4jgql/internal/app/graph/generated"
 	"github.com/charlysan/goneo4jgql/internal/app/repository"
 	"github.com/charlysan/goneo4jgql/internal/app/service"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/httpcache"
+	"github.com/charlysan/goneo4jgql/pkg/limits"
 	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/charlysan/goneo4jgql/pkg/metrics"
+	"github.com/charlysan/goneo4jgql/pkg/persisted"
+	"github.com/charlysan/goneo4jgql/pkg/ratelimit"
+	"github.com/charlysan/goneo4jgql/pkg/tracing"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/rs/cors"
 	"github.com/spf13/viper"
 )
 
@@ -20,50 +38,471 @@ import (
 type App struct {
 	Router  *mux.Router
 	Service service.Service
+	Driver  neo4j.Driver
+	// Logger is injected into the service and the repository
+	Logger *logger.Entry
+	// Profile controls the playground, introspection and error details
+	Profile Profile
+	// Authenticator validates JWTs and API keys sent to /movies, which is open when nil
+	Authenticator *auth.Authenticator
+	// APIKeys backs the API keys admin API, disabled when nil
+	APIKeys *auth.APIKeys
+	// Cache, when set, caches the results of the repository
+	Cache *repository.CachingRepository
+	// CORS, when set, lets browsers on other origins call the API
+	CORS *cors.Options
+	// Safelist, when set, holds the only operations /movies executes
+	Safelist *persisted.Safelist
+	// RateLimiter limits the rate of /movies requests of every client, unlimited when nil
+	RateLimiter *ratelimit.Limiter
+
+	websockets      *websocketTracker
+	shutdownTracing func()
 }
 
 // Init initializes app
 func Init() *App {
 	viper.AutomaticEnv()
 
+	// Optional config file, using the same keys as env vars. It is read again on SIGHUP.
+	if configFile := viper.GetString("CONFIG_FILE"); configFile != "" {
+		viper.SetConfigFile(configFile)
+		if err := viper.ReadInConfig(); err != nil {
+			logger.Fatal("Cannot read config file", err, logger.LogFields{"config_file": configFile})
+			os.Exit(1)
+		}
+	}
+
 	// Set default values
+	viper.SetDefault("APP_ENV", ProfileProd)
 	viper.SetDefault("API_PORT", "8080")
+	viper.SetDefault("API_READ_TIMEOUT", "15s")
+	viper.SetDefault("API_WRITE_TIMEOUT", "30s")
+	viper.SetDefault("API_IDLE_TIMEOUT", "60s")
+	viper.SetDefault("API_SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("ADMIN_TOKEN", "")
 	viper.SetDefault("LOGGER_FORMATTER", "console")
 	viper.SetDefault("LOGGER_LEVEL", "debug")
+	viper.SetDefault("LOGGER_PACKAGE_LEVELS", "")
+	viper.SetDefault("LOGGER_OUTPUT", "stderr")
+	viper.SetDefault("LOGGER_FILE_MAX_SIZE", 100)
+	viper.SetDefault("LOGGER_FILE_MAX_AGE", 7)
+	viper.SetDefault("LOGGER_FILE_MAX_BACKUPS", 5)
+	viper.SetDefault("LOGGER_FILE_COMPRESS", false)
+	viper.SetDefault("LOGGER_FILE_ROTATE_INTERVAL", "0s")
+	viper.SetDefault("LOGGER_SAMPLING", "")
+	viper.SetDefault("LOGGER_TRACE_DEDUP_INTERVAL", "1m")
+	viper.SetDefault("REPOSITORY_BACKEND", "neo4j")
+	viper.SetDefault("REPOSITORY_FIXTURE", "neo4j/import/movies.cypher")
+	viper.SetDefault("REPOSITORY_CACHE_TTL", "1m")
+	viper.SetDefault("REPOSITORY_CACHE_SIZE", 10000)
 	viper.SetDefault("NEO4J_HOST", "localhost")
 	viper.SetDefault("NEO4J_PORT", "7687")
 	viper.SetDefault("NEO4J_USER", "neo4j")
 	viper.SetDefault("NEO4J_PASS", "test")
 	viper.SetDefault("NEO4J_PROTO", "bolt")
+	viper.SetDefault("NEO4J_MAX_POOL_SIZE", 100)
+	viper.SetDefault("NEO4J_SLOW_QUERY_THRESHOLD", "500ms")
+	viper.SetDefault("NEO4J_SLOW_QUERY_PLAN", "none")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_FILE", "traces.json")
+	viper.SetDefault("TRACING_OTLP_ENDPOINT", "localhost:55680")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("TRACING_SERVICE_NAME", "goneo4jgql")
+	viper.SetDefault("AUTH_JWT_SECRET", "")
+	viper.SetDefault("AUTH_JWT_JWKS_FILE", "")
+	viper.SetDefault("AUTH_JWT_ISSUER", "")
+	viper.SetDefault("AUTH_JWT_AUDIENCE", "")
+	viper.SetDefault("AUTH_JWT_ROLES_CLAIM", "roles")
+	viper.SetDefault("AUTH_ALLOW_ANONYMOUS", false)
+	viper.SetDefault("AUTH_API_KEYS", "none")
+	viper.SetDefault("AUTH_API_KEYS_FILE", "api_keys.json")
+	viper.SetDefault("GRAPHQL_MAX_COMPLEXITY", 2000)
+	viper.SetDefault("GRAPHQL_MAX_DEPTH", 8)
+	viper.SetDefault("GRAPHQL_MAX_INTROSPECTION_DEPTH", 15)
+	viper.SetDefault("GRAPHQL_APQ_CACHE_SIZE", 1000)
+	viper.SetDefault("GRAPHQL_SAFELIST_DIR", "")
+	viper.SetDefault("CORS_ALLOWED_ORIGINS", "")
+	viper.SetDefault("CORS_ALLOWED_METHODS", "GET,POST,OPTIONS")
+	viper.SetDefault("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,X-API-Key")
+	viper.SetDefault("CORS_EXPOSED_HEADERS", "ETag,Retry-After")
+	viper.SetDefault("CORS_ALLOW_CREDENTIALS", false)
+	viper.SetDefault("CORS_MAX_AGE", "10m")
+	viper.SetDefault("SECURITY_HSTS_MAX_AGE", "0s")
+	viper.SetDefault("RATE_LIMIT_RATE", 0)
+	viper.SetDefault("RATE_LIMIT_BURST", 60)
+	viper.SetDefault("RATE_LIMIT_IP_RATE", 0)
+	viper.SetDefault("RATE_LIMIT_IP_BURST", 0)
+	viper.SetDefault("RATE_LIMIT_COMPLEXITY_UNIT", 0)
+	viper.SetDefault("RATE_LIMIT_TRUSTED_PROXIES", 0)
 
-	neo4Conn, err := repository.NewNeo4jConnection()
+	shutdownTracing, err := tracing.Init()
 	if err != nil {
 		logger.Fatal(err)
 		os.Exit(1)
 	}
 
-	r := &repository.Neo4jRepository{
-		Connection: neo4Conn,
+	log := logger.Default()
+
+	profile, overridden, err := loadProfile()
+	if err != nil {
+		logger.Fatal(err)
+		os.Exit(1)
+	}
+	log.Info("Loaded profile", logger.LogFields{
+		"profile":       profile.Name,
+		"playground":    profile.Playground,
+		"introspection": profile.Introspection,
+		"error_details": profile.ErrorDetails,
+	})
+	if profile.Name == ProfileProd && len(overridden) > 0 {
+		log.Warning("Production profile overridden", logger.LogFields{"overridden": strings.Join(overridden, ",")})
+	}
+
+	var r repository.Repository
+	var neo4Conn neo4j.Driver
+
+	switch backend := viper.GetString("REPOSITORY_BACKEND"); backend {
+	case repository.BackendMemory:
+		fixture := viper.GetString("REPOSITORY_FIXTURE")
+		memRepo, err := repository.NewInMemoryRepository(fixture)
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+
+		movies, people, relationships := memRepo.Size()
+		log.Info("Loaded in-memory repository", logger.LogFields{
+			"fixture":       fixture,
+			"movies":        movies,
+			"people":        people,
+			"relationships": relationships,
+		})
+		r = memRepo
+	case repository.BackendNeo4j:
+		neo4Conn, err = repository.NewNeo4jConnection()
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+
+		r = &repository.Neo4jRepository{
+			Connection: neo4Conn,
+			Logger:     log,
+		}
+	default:
+		logger.Fatal("Invalid repository backend", logger.LogFields{"backend": backend})
+		os.Exit(1)
+	}
+
+	var cache *repository.CachingRepository
+	if ttl := viper.GetDuration("REPOSITORY_CACHE_TTL"); ttl > 0 {
+		size := viper.GetInt("REPOSITORY_CACHE_SIZE")
+		if size <= 0 {
+			logger.Fatal("Invalid repository cache size", logger.LogFields{"size": size})
+			os.Exit(1)
+		}
+		cache = repository.NewCachingRepository(r, ttl, size)
+		r = cache
+	}
+
+	var apiKeys *auth.APIKeys
+	switch store := viper.GetString("AUTH_API_KEYS"); store {
+	case "none":
+	case "file":
+		fileStore, err := auth.NewFileKeyStore(viper.GetString("AUTH_API_KEYS_FILE"))
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+		apiKeys = auth.NewAPIKeys(fileStore, log)
+	case "neo4j":
+		// Keys can be stored in Neo4j while movies are served from memory
+		if neo4Conn == nil {
+			neo4Conn, err = repository.NewNeo4jConnection()
+			if err != nil {
+				logger.Fatal(err)
+				os.Exit(1)
+			}
+		}
+		apiKeys = auth.NewAPIKeys(&repository.Neo4jRepository{Connection: neo4Conn, Logger: log}, log)
+	default:
+		logger.Fatal("Invalid API keys store", logger.LogFields{"store": store})
+		os.Exit(1)
+	}
+
+	var authenticator *auth.Authenticator
+	if viper.GetString("AUTH_JWT_SECRET") != "" || viper.GetString("AUTH_JWT_JWKS_FILE") != "" || apiKeys != nil {
+		authenticator, err = auth.NewAuthenticator(auth.Config{
+			Secret:         viper.GetString("AUTH_JWT_SECRET"),
+			JWKSFile:       viper.GetString("AUTH_JWT_JWKS_FILE"),
+			Issuer:         viper.GetString("AUTH_JWT_ISSUER"),
+			Audience:       viper.GetString("AUTH_JWT_AUDIENCE"),
+			RolesClaim:     viper.GetString("AUTH_JWT_ROLES_CLAIM"),
+			AllowAnonymous: viper.GetBool("AUTH_ALLOW_ANONYMOUS"),
+			APIKeys:        apiKeys,
+		}, log)
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+	}
+
+	corsConfig, err := corsOptions()
+	if err != nil {
+		logger.Fatal(err)
+		os.Exit(1)
+	}
+
+	var safelist *persisted.Safelist
+	if dir := viper.GetString("GRAPHQL_SAFELIST_DIR"); dir != "" {
+		safelist, err = persisted.LoadSafelist(dir)
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+		log.Info("Loaded operations safelist", logger.LogFields{"dir": dir, "operations": safelist.Len()})
+	}
+
+	var rateLimiter *ratelimit.Limiter
+	if rate := viper.GetFloat64("RATE_LIMIT_RATE"); rate > 0 {
+		rateLimiter, err = ratelimit.New(ratelimit.Config{
+			Limit:          ratelimit.Limit{Rate: rate, Burst: viper.GetInt("RATE_LIMIT_BURST")},
+			IPLimit:        ipLimit(rate, viper.GetInt("RATE_LIMIT_BURST")),
+			ComplexityUnit: viper.GetInt("RATE_LIMIT_COMPLEXITY_UNIT"),
+			TrustedProxies: viper.GetInt("RATE_LIMIT_TRUSTED_PROXIES"),
+		}, ratelimit.NewMemoryStore(), log)
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
 	}
 
 	return &App{
-		Service: service.NewService(r),
+		Service:         service.NewService(r, log),
+		Driver:          neo4Conn,
+		Logger:          log,
+		Profile:         profile,
+		Authenticator:   authenticator,
+		APIKeys:         apiKeys,
+		Cache:           cache,
+		CORS:            corsConfig,
+		Safelist:        safelist,
+		RateLimiter:     rateLimiter,
+		websockets:      newWebsocketTracker(),
+		shutdownTracing: shutdownTracing,
 	}
 
 }
 
-// Run executes app
+// Run executes app and blocks until SIGINT or SIGTERM is received.
+// In-flight requests are given API_SHUTDOWN_TIMEOUT to finish, and websocket
+// subscriptions are closed, before the server and the Neo4j driver are closed.
 func (a *App) Run() {
 	addrStr := fmt.Sprintf("0.0.0.0:%s", viper.Get("API_PORT"))
-	logger.Info("API Listening", logger.LogFields{"api_url": addrStr})
-	logger.Fatal("Service failure", http.ListenAndServe(addrStr, a.Router))
+
+	srv := &http.Server{
+		Addr:         addrStr,
+		Handler:      a.Router,
+		ReadTimeout:  viper.GetDuration("API_READ_TIMEOUT"),
+		WriteTimeout: viper.GetDuration("API_WRITE_TIMEOUT"),
+		IdleTimeout:  viper.GetDuration("API_IDLE_TIMEOUT"),
+	}
+	// Subscriptions run on hijacked connections that http.Server.Shutdown neither
+	// closes nor waits for, so they are closed when it starts, then drained
+	srv.RegisterOnShutdown(a.websockets.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		a.Logger.Info("API Listening", logger.LogFields{"api_url": addrStr})
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+wait:
+	for {
+		select {
+		case err := <-serverErr:
+			a.closeDriver()
+			logger.Fatal("Service failure", err)
+		case <-reload:
+			a.reloadConfig()
+		case sig := <-stop:
+			a.Logger.Info("Shutting down", logger.LogFields{"signal": sig.String()})
+			break wait
+		}
+	}
+
+	gracePeriod := viper.GetDuration("API_SHUTDOWN_TIMEOUT")
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		a.Logger.Warning("HTTP server did not drain in time", err, logger.LogFields{"grace_period": gracePeriod.String()})
+	}
+
+	if err := a.websockets.Wait(ctx); err != nil {
+		a.Logger.Warning("Websocket subscriptions did not drain in time", err, logger.LogFields{"active": a.websockets.Active()})
+	}
+
+	srv.Close()
+	a.closeDriver()
+
+	if a.shutdownTracing != nil {
+		a.shutdownTracing()
+	}
+
+	a.Logger.Info("Shutdown complete")
+}
+
+// reloadConfig purges the repository cache, reads the config file again (if any) and
+// applies log levels and sampling
+func (a *App) reloadConfig() {
+	if a.Cache != nil {
+		a.Logger.Info("Purged repository cache", logger.LogFields{"entries": a.Cache.Purge()})
+	}
+
+	if viper.ConfigFileUsed() != "" {
+		if err := viper.ReadInConfig(); err != nil {
+			a.Logger.Error("Cannot reload config file", err, logger.LogFields{"config_file": viper.ConfigFileUsed()})
+			return
+		}
+	}
+
+	if err := logger.ReloadLevels(); err != nil {
+		a.Logger.Error("Cannot reload log levels", err)
+		return
+	}
+
+	if err := logger.ReloadSampling(); err != nil {
+		a.Logger.Error("Cannot reload log sampling", err)
+		return
+	}
+
+	level, packages := logger.Levels()
+	a.Logger.Info("Log levels reloaded", logger.LogFields{"level": level, "packages": packages})
+}
+
+// closeDriver closes the Neo4j driver, if any
+func (a *App) closeDriver() {
+	if a.Driver == nil {
+		return
+	}
+
+	if err := a.Driver.Close(); err != nil {
+		a.Logger.Error("Cannot close Neo4j driver", err)
+		return
+	}
+
+	a.Logger.Info("Neo4j driver closed")
+}
+
+// ipLimit returns the limit of client addresses, from RATE_LIMIT_IP_RATE and
+// RATE_LIMIT_IP_BURST, which default to the limit of clients
+func ipLimit(rate float64, burst int) ratelimit.Limit {
+	limit := ratelimit.Limit{Rate: viper.GetFloat64("RATE_LIMIT_IP_RATE"), Burst: viper.GetInt("RATE_LIMIT_IP_BURST")}
+	if limit.Rate == 0 {
+		limit.Rate = rate
+	}
+	if limit.Burst == 0 {
+		limit.Burst = burst
+	}
+
+	return limit
 }
 
 // InitRoutes initializing all the routes
 func (a *App) InitRoutes() {
 	a.Router = mux.NewRouter()
 
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{Service: a.Service}}))
-	a.Router.Handle("/playground", playground.Handler("GoNeo4jGql GraphQL playground", "/movies"))
-	a.Router.Handle("/movies", srv)
+	if a.websockets == nil {
+		a.websockets = newWebsocketTracker()
+	}
+
+	// Same transports and extensions as handler.NewDefaultServer, with a configurable
+	// persisted queries cache
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  &graph.Resolver{Service: &a.Service, Keys: a.APIKeys, Cache: a.Cache},
+		Directives: graph.Directives(),
+		Complexity: graph.Complexity(),
+	}))
+	ws := transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader:              websocket.Upgrader{CheckOrigin: checkOrigin(a.CORS)},
+	}
+	if a.Authenticator != nil {
+		ws.InitFunc = a.Authenticator.WebsocketInit
+	}
+	srv.AddTransport(ws)
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New(1000))
+	srv.SetErrorPresenter(graph.ErrorPresenter(a.Profile.ErrorDetails))
+	srv.SetRecoverFunc(graph.Recover(a.Profile.ErrorDetails))
+	if a.Profile.Introspection {
+		srv.Use(extension.Introspection{})
+	}
+
+	// In safelist mode, persisted queries are the approved operations, and any other is
+	// rejected. Metrics are labelled with the names of approved operations only.
+	var operations []string
+	if a.Safelist != nil {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: a.Safelist})
+		srv.Use(a.Safelist)
+		operations = a.Safelist.OperationNames()
+	} else if size := viper.GetInt("GRAPHQL_APQ_CACHE_SIZE"); size > 0 {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(size)})
+	}
+
+	srv.Use(metrics.NewTracer(operations))
+	srv.Use(tracing.Tracer{})
+	srv.Use(logger.Tracer{})
+	if limit := viper.GetInt("GRAPHQL_MAX_COMPLEXITY"); limit > 0 {
+		srv.Use(extension.FixedComplexityLimit(limit))
+	}
+	if depth := viper.GetInt("GRAPHQL_MAX_DEPTH"); depth > 0 {
+		srv.Use(limits.DepthLimit{Max: depth, MaxIntrospection: viper.GetInt("GRAPHQL_MAX_INTROSPECTION_DEPTH")})
+	}
+
+	a.Router.Use(logger.RequestIDMiddleware)
+	a.Router.Use(tracing.Middleware)
+	a.Router.Use(securityHeaders(viper.GetDuration("SECURITY_HSTS_MAX_AGE")))
+	if a.CORS != nil {
+		a.Router.Use(cors.New(*a.CORS).Handler)
+	}
+	a.Router.Handle("/metrics", metrics.Handler())
+
+	if token := viper.GetString("ADMIN_TOKEN"); token != "" {
+		a.Router.Handle("/admin/log-level", adminOnly(token, logger.LevelHandler()))
+	}
+	if a.Profile.Playground {
+		a.Router.Handle("/playground", playground.Handler("GoNeo4jGql GraphQL playground", "/movies"))
+	}
+
+	// Cache headers are set after authentication, so responses to authenticated requests are private
+	srv.Use(&httpcache.Hints{})
+	var movies http.Handler = httpcache.Middleware(srv)
+	// Rate limits apply after authentication, to tell authenticated clients apart
+	if a.RateLimiter != nil {
+		srv.Use(a.RateLimiter)
+		movies = a.RateLimiter.Middleware(movies)
+	}
+	if a.Authenticator != nil {
+		srv.Use(auth.ReadOnly{})
+		movies = a.Authenticator.Middleware(movies)
+	}
+	// Client addresses are limited before authentication, so failed authentications are too
+	if a.RateLimiter != nil {
+		movies = a.RateLimiter.IPMiddleware(movies)
+	}
+	a.Router.Handle("/movies", a.websockets.Middleware(movies))
 }
@@ -0,0 +1,65 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/generated"
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+)
+
+func (r *mutationResolver) IssueAPIKey(ctx context.Context, name string, scopes []model.Role, expiresAt *time.Time) (*model.IssuedAPIKey, error) {
+	if r.Keys == nil {
+		return nil, errAPIKeysDisabled
+	}
+
+	// Scopes are roles, stored with their canonical name
+	roles := make([]string, len(scopes))
+	for i, scope := range scopes {
+		roles[i] = string(scope)
+	}
+
+	key, apiKey, err := r.Keys.Issue(ctx, name, roles, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.IssuedAPIKey{Key: key, APIKey: apiKey}, nil
+}
+
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (*auth.APIKey, error) {
+	if r.Keys == nil {
+		return nil, errAPIKeysDisabled
+	}
+
+	return r.Keys.Revoke(ctx, id)
+}
+
+func (r *mutationResolver) PurgeCache(ctx context.Context) (*int, error) {
+	if r.Cache == nil {
+		return nil, errCacheDisabled
+	}
+
+	n := r.Cache.Purge()
+	logger.FromContext(ctx).Info("Purged repository cache", logger.LogFields{"entries": n})
+
+	return &n, nil
+}
+
+func (r *queryResolver) APIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	if r.Keys == nil {
+		return nil, errAPIKeysDisabled
+	}
+
+	return r.Keys.List(ctx)
+}
+
+// Mutation returns generated.MutationResolver implementation.
+func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
+
+type mutationResolver struct{ *Resolver }
@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"errors"
+	"math"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/generated"
+)
+
+// Estimated list sizes, used to weight list fields queried without limit
+const (
+	estimatedMovies         = 40
+	estimatedFilteredMovies = 5
+	estimatedCast           = 10
+	estimatedParticipations = 5
+	estimatedCrew           = 2
+)
+
+var errNegativeLimit = errors.New("limit must not be negative")
+
+// Complexity returns the cost functions of list fields: a list costs its child
+// complexity times the number of items it returns, that is its limit argument or
+// an estimation when there is none
+func Complexity() generated.ComplexityRoot {
+	c := generated.ComplexityRoot{}
+
+	c.Query.Movies = func(childComplexity int, title *string, actor *string, limit *int) int {
+		if title != nil || actor != nil {
+			return listCost(childComplexity, limit, estimatedFilteredMovies)
+		}
+		return listCost(childComplexity, limit, estimatedMovies)
+	}
+	c.Movie.Cast = func(childComplexity int, limit *int) int {
+		return listCost(childComplexity, limit, estimatedCast)
+	}
+	c.Movie.Directors = func(childComplexity int) int {
+		return listCost(childComplexity, nil, estimatedCrew)
+	}
+	c.Movie.Writers = func(childComplexity int) int {
+		return listCost(childComplexity, nil, estimatedCrew)
+	}
+	c.Movie.Reviewers = func(childComplexity int) int {
+		return listCost(childComplexity, nil, estimatedCrew)
+	}
+	c.Person.Participated = func(childComplexity int, limit *int) int {
+		return listCost(childComplexity, limit, estimatedParticipations)
+	}
+
+	return c
+}
+
+// listCost returns 1 + n * childComplexity, n being the limit if set, saturating
+// instead of overflowing for huge limits
+func listCost(childComplexity int, limit *int, estimate int) int {
+	n := estimate
+	if limit != nil && *limit >= 0 {
+		n = *limit
+	}
+
+	if n > 0 && childComplexity > (math.MaxInt32-1)/n {
+		return math.MaxInt32
+	}
+
+	return 1 + n*childComplexity
+}
+
+// validateLimit rejects negative limit arguments
+func validateLimit(limit *int) error {
+	if limit != nil && *limit < 0 {
+		return errNegativeLimit
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/charlysan/goneo4jgql/internal/app/graph/generated"
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Directives returns the implementation of the schema directives
+func Directives() generated.DirectiveRoot {
+	return generated.DirectiveRoot{
+		Auth:    Auth,
+		HasRole: HasRole,
+	}
+}
+
+// Auth resolves a field for authenticated callers only. Other callers get null and
+// an error for the field, while the rest of the operation is still resolved.
+func Auth(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if auth.FromContext(ctx) == nil {
+		return nil, denied(ctx, "UNAUTHENTICATED", "authentication required")
+	}
+
+	return next(ctx)
+}
+
+// HasRole resolves a field for authenticated callers granted role (or ADMIN) only.
+// Other callers get null and an error for the field.
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+	p := auth.FromContext(ctx)
+	if p == nil {
+		return nil, denied(ctx, "UNAUTHENTICATED", "authentication required")
+	}
+
+	if !p.HasRole(string(role)) && !p.HasRole(string(model.RoleAdmin)) {
+		return nil, denied(ctx, "FORBIDDEN", fmt.Sprintf("%s role required", role))
+	}
+
+	return next(ctx)
+}
+
+func denied(ctx context.Context, code string, message string) error {
+	fc := graphql.GetFieldContext(ctx)
+	logger.FromContext(ctx).Debug("Field access denied", logger.LogFields{
+		"field":  fmt.Sprintf("%s.%s", fc.Object, fc.Field.Name),
+		"reason": message,
+	})
+
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
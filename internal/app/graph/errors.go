@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	goerrors "github.com/go-errors/errors"
+	validator "github.com/go-playground/validator/v10"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errInternal = "INTERNAL_SERVER_ERROR"
+
+// introspectionDisabled is the message of the (unexported) error returned by the
+// generated code when introspection is disabled
+const introspectionDisabled = "introspection disabled"
+
+// publicErrors are meant for clients, and returned as they are
+var publicErrors = []error{
+	errNegativeLimit,
+	errAPIKeysDisabled,
+	errCacheDisabled,
+	auth.ErrKeyNotFound,
+	auth.ErrKeyNameRequired,
+}
+
+// ErrorPresenter returns the function turning resolver errors into GraphQL errors.
+// Unless details is set, errors that are not meant for clients (e.g. Neo4j failures)
+// are replaced by an internal error carrying the request id, to be found in the logs.
+func ErrorPresenter(details bool) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		if details || isPublic(err) {
+			return graphql.DefaultErrorPresenter(ctx, err)
+		}
+
+		// Logged with the request id returned to the client, so it can be found
+		logger.FromContext(ctx).Error("Error hidden from client", err)
+
+		return internalError(ctx, nil)
+	}
+}
+
+// Recover returns the function handling resolver panics, which are logged with their
+// stack trace. The panic and its stack trace are returned to clients when details is set.
+func Recover(details bool) graphql.RecoverFunc {
+	return func(ctx context.Context, p interface{}) error {
+		err := goerrors.Wrap(p, 2)
+		logger.FromContext(ctx).Error("Resolver panic", err)
+
+		if !details {
+			return internalError(ctx, nil)
+		}
+
+		return internalError(ctx, &gqlerror.Error{
+			Message: fmt.Sprintf("panic: %v", p),
+			Extensions: map[string]interface{}{
+				"stacktrace": strings.Split(strings.TrimSpace(string(err.Stack())), "\n"),
+			},
+		})
+	}
+}
+
+// isPublic tells whether err is meant for clients: GraphQL errors (raised by
+// directives, extensions or validation), input validation errors, publicErrors
+// and disabled introspection
+func isPublic(err error) bool {
+	var gqlErr *gqlerror.Error
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &gqlErr) || errors.As(err, &validationErrs) || err.Error() == introspectionDisabled {
+		return true
+	}
+
+	for _, public := range publicErrors {
+		if errors.Is(err, public) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// internalError returns err (a generic internal error if nil) with the internal error
+// code and the request id
+func internalError(ctx context.Context, err *gqlerror.Error) *gqlerror.Error {
+	if err == nil {
+		err = &gqlerror.Error{Message: "internal server error"}
+	}
+	if err.Extensions == nil {
+		err.Extensions = map[string]interface{}{}
+	}
+	if graphql.GetFieldContext(ctx) != nil {
+		err.Path = graphql.GetFieldContext(ctx).Path()
+	}
+
+	err.Extensions["code"] = errInternal
+	if id := logger.RequestID(ctx); id != "" {
+		err.Extensions["requestId"] = id
+	}
+
+	return err
+}
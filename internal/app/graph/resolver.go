@@ -3,10 +3,23 @@
 package graph
 
 import (
+	"errors"
+
+	"github.com/charlysan/goneo4jgql/internal/app/repository"
 	"github.com/charlysan/goneo4jgql/internal/app/service"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+)
+
+var (
+	errAPIKeysDisabled = errors.New("API keys are not enabled")
+	errCacheDisabled   = errors.New("repository cache is not enabled")
 )
 
 // Resolver is the main gql resolver
 type Resolver struct {
-	Service service.Service
+	Service service.MovieService
+	// Keys backs the API keys admin API, which returns errors when nil
+	Keys *auth.APIKeys
+	// Cache is purged by the purgeCache mutation, which returns an error when nil
+	Cache *repository.CachingRepository
 }
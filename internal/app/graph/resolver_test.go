@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/internal/app/service/mocks"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestResolver(t *testing.T) (*Resolver, *mocks.MovieService) {
+	s := &mocks.MovieService{}
+	s.Test(t)
+
+	return &Resolver{Service: s}, s
+}
+
+func str(s string) *string {
+	return &s
+}
+
+func TestMoviesValidation(t *testing.T) {
+	matrix := []*models.Movie{{UUID: "1", Title: "The Matrix"}}
+
+	tests := []struct {
+		name  string
+		title *string
+		actor *string
+		valid bool
+	}{
+		{name: "no filters", valid: true},
+		{name: "title", title: str("matrix"), valid: true},
+		{name: "actor", actor: str("keanu"), valid: true},
+		{name: "title and actor", title: str("matrix"), actor: str("keanu"), valid: true},
+		{name: "title with surrounding spaces", title: str("  matrix "), valid: true},
+		{name: "short title", title: str("ma")},
+		{name: "short title with spaces", title: str(" ma  ")},
+		{name: "empty title", title: str("")},
+		{name: "title with spaces", title: str("the matrix")},
+		{name: "title with symbols", title: str("matrix!")},
+		{name: "short actor", actor: str("ke")},
+		{name: "actor with symbols", title: str("matrix"), actor: str("keanu;")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, s := newTestResolver(t)
+			if tt.valid {
+				s.On("FindMovies", mock.Anything, tt.title, tt.actor, (*int)(nil)).Return(matrix, nil)
+			}
+
+			movies, err := r.Query().Movies(context.Background(), tt.title, tt.actor, nil)
+
+			if tt.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, matrix, movies)
+			} else {
+				assert.NotNil(t, err)
+				assert.Nil(t, movies)
+				s.AssertNotCalled(t, "FindMovies", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			s.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	movie := &models.Movie{UUID: "1"}
+	person := &models.Person{UUID: "2"}
+	serviceErr := errors.New("Neo4j unavailable")
+
+	tests := []struct {
+		method  string
+		args    []interface{}
+		ret     interface{}
+		resolve func(r *Resolver) (interface{}, error)
+	}{
+		{
+			method: "FindMovieByUUID",
+			args:   []interface{}{"1"},
+			ret:    (*models.Movie)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Query().Movie(context.Background(), "1")
+			},
+		},
+		{
+			method: "FindMovies",
+			args:   []interface{}{str("matrix"), (*string)(nil), (*int)(nil)},
+			ret:    ([]*models.Movie)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Query().Movies(context.Background(), str("matrix"), nil, nil)
+			},
+		},
+		{
+			method: "FindDirectorsByMovieUUID",
+			args:   []interface{}{"1"},
+			ret:    ([]*models.Person)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Movie().Directors(context.Background(), movie)
+			},
+		},
+		{
+			method: "FindWritersByMovieUUID",
+			args:   []interface{}{"1"},
+			ret:    ([]*models.Person)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Movie().Writers(context.Background(), movie)
+			},
+		},
+		{
+			method: "FindCastByMovieUUID",
+			args:   []interface{}{"1", (*int)(nil)},
+			ret:    ([]*models.Person)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Movie().Cast(context.Background(), movie, nil)
+			},
+		},
+		{
+			method: "FindMovieParticipationsByPersonUUID",
+			args:   []interface{}{"2", (*int)(nil)},
+			ret:    ([]*model.Participation)(nil),
+			resolve: func(r *Resolver) (interface{}, error) {
+				return r.Person().Participated(context.Background(), person, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			r, s := newTestResolver(t)
+			s.On(tt.method, append([]interface{}{mock.Anything}, tt.args...)...).Return(nil, serviceErr)
+
+			res, err := tt.resolve(r)
+
+			assert.Equal(t, serviceErr, err)
+			assert.Equal(t, tt.ret, res)
+			s.AssertExpectations(t)
+		})
+	}
+}
+
+func intp(i int) *int {
+	return &i
+}
+
+func TestResolverLimits(t *testing.T) {
+	movie := &models.Movie{UUID: "1"}
+	cast := []*models.Person{{UUID: "2"}, {UUID: "3"}}
+
+	tests := []struct {
+		name  string
+		limit *int
+		err   error
+	}{
+		{name: "no limit"},
+		{name: "limit", limit: intp(2)},
+		{name: "zero limit", limit: intp(0)},
+		{name: "negative limit", limit: intp(-1), err: errNegativeLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, s := newTestResolver(t)
+			if tt.err == nil {
+				// Limits are applied by the service, not to its results
+				s.On("FindCastByMovieUUID", mock.Anything, "1", tt.limit).Return(cast, nil)
+			}
+
+			res, err := r.Movie().Cast(context.Background(), movie, tt.limit)
+
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, cast, res)
+			} else {
+				assert.Nil(t, res)
+			}
+			s.AssertExpectations(t)
+		})
+	}
+}
+
+func TestComplexity(t *testing.T) {
+	c := Complexity()
+
+	assert.Equal(t, 1+40*3, c.Query.Movies(3, nil, nil, nil))
+	assert.Equal(t, 1+5*3, c.Query.Movies(3, str("matrix"), nil, nil))
+	assert.Equal(t, 1+2*3, c.Query.Movies(3, nil, nil, intp(2)))
+	assert.Equal(t, 1, c.Movie.Cast(3, intp(0)))
+	assert.Equal(t, 1+10*3, c.Movie.Cast(3, intp(-1)))
+	assert.Equal(t, 1+2*4, c.Movie.Directors(4))
+	assert.Equal(t, 1+5*2, c.Person.Participated(2, nil))
+
+	// Huge limits saturate
+	assert.Equal(t, math.MaxInt32, c.Query.Movies(2, nil, nil, intp(math.MaxInt32)))
+}
+
+func TestErrorPresenter(t *testing.T) {
+	capture := logger.NewCaptureSink()
+	logger.SetSink(capture)
+	t.Cleanup(func() { logger.SetSink(nil) })
+
+	ctx := logger.NewContext(context.Background(), logger.LogFields{logger.FieldRequestID: "abc"})
+	failure := errors.New("neo4j unavailable")
+
+	err := ErrorPresenter(false)(ctx, failure)
+	assert.Equal(t, "internal server error", err.Message)
+	assert.Equal(t, map[string]interface{}{"code": "INTERNAL_SERVER_ERROR", "requestId": "abc"}, err.Extensions)
+
+	// Hidden errors are logged with the request id sent to the client
+	logged := capture.Find("Error hidden from client")
+	if assert.Len(t, logged, 1) {
+		assert.Equal(t, logger.LevelError, logged[0].Level)
+		assert.Equal(t, "abc", logged[0].Fields[logger.FieldRequestID])
+		assert.Equal(t, "neo4j unavailable", logged[0].Fields["error_msg"])
+	}
+
+	assert.Equal(t, "neo4j unavailable", ErrorPresenter(true)(ctx, failure).Message)
+	assert.Equal(t, errNegativeLimit.Error(), ErrorPresenter(false)(ctx, errNegativeLimit).Message)
+	assert.Len(t, capture.Find("Error hidden from client"), 1)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/charlysan/goneo4jgql/internal/app/graph/generated"
 	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
 	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
 	validator "github.com/go-playground/validator/v10"
 )
 
@@ -31,8 +32,12 @@ func (r *movieResolver) Writers(ctx context.Context, obj *models.Movie) ([]*mode
 	return ws, nil
 }
 
-func (r *movieResolver) Cast(ctx context.Context, obj *models.Movie) ([]*models.Person, error) {
-	c, err := r.Service.FindCastByMovieUUID(ctx, obj.UUID)
+func (r *movieResolver) Cast(ctx context.Context, obj *models.Movie, limit *int) ([]*models.Person, error) {
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	c, err := r.Service.FindCastByMovieUUID(ctx, obj.UUID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +45,21 @@ func (r *movieResolver) Cast(ctx context.Context, obj *models.Movie) ([]*models.
 	return c, nil
 }
 
-func (r *personResolver) Participated(ctx context.Context, obj *models.Person) ([]*model.Participation, error) {
-	p, err := r.Service.FindMovieParticipationsByPersonUUID(ctx, obj.UUID)
+func (r *movieResolver) Reviewers(ctx context.Context, obj *models.Movie) ([]*models.Person, error) {
+	rs, err := r.Service.FindReviewersByMovieUUID(ctx, obj.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (r *personResolver) Participated(ctx context.Context, obj *models.Person, limit *int) ([]*model.Participation, error) {
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	p, err := r.Service.FindMovieParticipationsByPersonUUID(ctx, obj.UUID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +77,7 @@ func (r *queryResolver) Movie(ctx context.Context, uuid string) (*models.Movie,
 	return mv, nil
 }
 
-func (r *queryResolver) Movies(ctx context.Context, title *string, actor *string) ([]*models.Movie, error) {
+func (r *queryResolver) Movies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
 	validator := validator.New()
 
 	// validate input
@@ -77,7 +95,11 @@ func (r *queryResolver) Movies(ctx context.Context, title *string, actor *string
 		}
 	}
 
-	movies, err := r.Service.FindMovies(ctx, title, actor)
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	movies, err := r.Service.FindMovies(ctx, title, actor, limit)
 
 	if err != nil {
 		return nil, err
@@ -86,6 +108,10 @@ func (r *queryResolver) Movies(ctx context.Context, title *string, actor *string
 	return movies, nil
 }
 
+func (r *queryResolver) Me(ctx context.Context) (*auth.Principal, error) {
+	return auth.FromContext(ctx), nil
+}
+
 // Movie returns generated.MovieResolver implementation.
 func (r *Resolver) Movie() generated.MovieResolver { return &movieResolver{r} }
 
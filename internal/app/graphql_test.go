@@ -0,0 +1,626 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/internal/app/repository"
+	"github.com/charlysan/goneo4jgql/internal/app/service"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/charlysan/goneo4jgql/pkg/persisted"
+	"github.com/charlysan/goneo4jgql/pkg/ratelimit"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/graphql")
+
+const moviesFixture = "../../neo4j/import/movies.cypher"
+
+// goldenResponse is the content of golden files
+type goldenResponse struct {
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// newTestApp returns an app serving r, with routes initialized
+func newTestApp(t *testing.T, r repository.Repository) *App {
+	log := logger.New(logger.NewCaptureSink())
+
+	a := &App{
+		Service: service.NewService(r, log),
+		Logger:  log,
+		Profile: profiles[ProfileDev],
+	}
+	a.InitRoutes()
+
+	return a
+}
+
+// runGoldenTests executes every .graphql operation in dir against a, comparing responses
+// with the .json golden file of the same name. Variables are read from an optional
+// .variables.json file. Golden files are written instead when running with -update.
+// When operations are run by several callers, each with its own request header, the
+// caller name is added to golden file names (<operation>.<caller>.json).
+func runGoldenTests(t *testing.T, a *App, dir string, caller string, header http.Header) {
+	operations, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) == 0 {
+		t.Fatalf("No operations found in %s", dir)
+	}
+
+	for _, operation := range operations {
+		base := strings.TrimSuffix(operation, ".graphql")
+
+		golden := base + ".json"
+		name := filepath.Base(base)
+		if caller != "" {
+			golden = base + "." + caller + ".json"
+			name += "/" + caller
+		}
+
+		t.Run(name, func(t *testing.T) {
+			query, err := ioutil.ReadFile(operation)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			variables := map[string]interface{}{}
+			if data, err := ioutil.ReadFile(base + ".variables.json"); err == nil {
+				if err := json.Unmarshal(data, &variables); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := execute(t, a, string(query), variables, header)
+
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Cannot read golden file (run with -update to create it): %v", err)
+			}
+
+			assert.Equal(t, string(expected), string(got))
+		})
+	}
+}
+
+// execute posts a GraphQL operation and returns the status and response as indented JSON
+func execute(t *testing.T, a *App, query string, variables map[string]interface{}, header http.Header) []byte {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/movies", bytes.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	a.Router.ServeHTTP(rec, req)
+
+	res := goldenResponse{Status: rec.Code, Response: rec.Body.Bytes()}
+	if !json.Valid(res.Response) {
+		res.Response, _ = json.Marshal(rec.Body.String())
+	}
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(out, '\n')
+}
+
+func TestGraphQL(t *testing.T) {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGoldenTests(t, newTestApp(t, r), "testdata/graphql", "", nil)
+}
+
+// failingRepository fails to find people, to check how errors propagate to responses
+type failingRepository struct {
+	repository.Repository
+}
+
+func (failingRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error) {
+	return nil, errors.New("Neo4j unavailable")
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	viper.Set("GRAPHQL_MAX_COMPLEXITY", 500)
+	viper.Set("GRAPHQL_MAX_DEPTH", 8)
+	viper.Set("GRAPHQL_MAX_INTROSPECTION_DEPTH", 15)
+	t.Cleanup(func() {
+		viper.Set("GRAPHQL_MAX_COMPLEXITY", 0)
+		viper.Set("GRAPHQL_MAX_DEPTH", 0)
+		viper.Set("GRAPHQL_MAX_INTROSPECTION_DEPTH", 0)
+	})
+
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGoldenTests(t, newTestApp(t, r), "testdata/graphql/limits", "", nil)
+}
+
+func TestGraphQLRepositoryErrors(t *testing.T) {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGoldenTests(t, newTestApp(t, failingRepository{r}), "testdata/graphql/repository_errors", "", nil)
+}
+
+const testSecret = "s3cr3t"
+
+// newAuthTestApp returns an app requiring JWTs signed with testSecret or, when keys is
+// not nil, API keys. Anonymous requests are allowed.
+func newAuthTestApp(t *testing.T, keys *auth.APIKeys) *App {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := logger.New(logger.NewCaptureSink())
+	a := &App{
+		Service: service.NewService(r, log),
+		Logger:  log,
+		Profile: profiles[ProfileDev],
+		APIKeys: keys,
+	}
+	a.Authenticator, err = auth.NewAuthenticator(auth.Config{Secret: testSecret, AllowAnonymous: true, APIKeys: keys}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.InitRoutes()
+
+	return a
+}
+
+// bearer returns a header with a JWT for subject, granted roles
+func bearer(t *testing.T, subject string, roles ...string) http.Header {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   subject,
+		"roles": roles,
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestGraphQLAuthorization(t *testing.T) {
+	a := newAuthTestApp(t, nil)
+
+	runGoldenTests(t, a, "testdata/graphql/authorization", "anonymous", nil)
+	runGoldenTests(t, a, "testdata/graphql/authorization", "viewer", bearer(t, "viewer", "viewer"))
+	runGoldenTests(t, a, "testdata/graphql/authorization", "editor", bearer(t, "editor", "viewer", "editor"))
+	runGoldenTests(t, a, "testdata/graphql/authorization", "admin", bearer(t, "admin", "ADMIN"))
+}
+
+// graphQLResponse is a decoded GraphQL response
+type graphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// post executes an operation, returning the status and the decoded response
+func post(t *testing.T, a *App, query string, variables map[string]interface{}, header http.Header) (int, *graphQLResponse) {
+	var res goldenResponse
+	if err := json.Unmarshal(execute(t, a, query, variables, header), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors other than 200 may not have a GraphQL response body
+	resp := &graphQLResponse{}
+	if err := json.Unmarshal(res.Response, resp); err != nil && res.Status == http.StatusOK {
+		t.Fatal(err)
+	}
+
+	return res.Status, resp
+}
+
+func TestGraphQLAPIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := auth.NewFileKeyStore(filepath.Join(dir, "api_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAuthTestApp(t, auth.NewAPIKeys(store, nil))
+	admin := bearer(t, "admin", "admin")
+
+	issue := `mutation Issue($scopes: [Role!]!) { issueApiKey(name: "batch", scopes: $scopes) { key apiKey { id scopes } } }`
+	me := `{ me { subject roles } }`
+
+	// Only admins can issue keys
+	_, resp := post(t, a, issue, map[string]interface{}{"scopes": []string{"VIEWER"}}, bearer(t, "viewer", "viewer"))
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "FORBIDDEN", resp.Errors[0].Extensions["code"])
+	}
+
+	// Scopes are roles, spelled as such
+	for _, scopes := range [][]string{{"VIEWR"}, {"admin"}} {
+		_, resp := post(t, a, issue, map[string]interface{}{"scopes": scopes}, admin)
+		if assert.Len(t, resp.Errors, 1, scopes) {
+			assert.Contains(t, resp.Errors[0].Message, "is not a valid Role", scopes)
+		}
+	}
+
+	status, resp := post(t, a, issue, map[string]interface{}{"scopes": []string{"VIEWER"}}, admin)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Errors)
+
+	var issued struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID     string   `json:"id"`
+			Scopes []string `json:"scopes"`
+		} `json:"apiKey"`
+	}
+	if err := json.Unmarshal(resp.Data["issueApiKey"], &issued); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"VIEWER"}, issued.APIKey.Scopes)
+
+	// The key authenticates its client, granted its scopes
+	key := http.Header{"X-Api-Key": {issued.Key}}
+	_, resp = post(t, a, me, nil, key)
+	assert.JSONEq(t, `{"subject": "apikey:`+issued.APIKey.ID+`", "roles": ["VIEWER"]}`, string(resp.Data["me"]))
+
+	_, resp = post(t, a, issue, map[string]interface{}{"scopes": []string{"ADMIN"}}, key)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "FORBIDDEN", resp.Errors[0].Extensions["code"])
+	}
+
+	_, resp = post(t, a, `{ apiKeys { id lastUsedAt revokedAt } }`, nil, admin)
+	var keys []map[string]interface{}
+	if err := json.Unmarshal(resp.Data["apiKeys"], &keys); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, issued.APIKey.ID, keys[0]["id"])
+		assert.NotNil(t, keys[0]["lastUsedAt"])
+		assert.Nil(t, keys[0]["revokedAt"])
+	}
+
+	// Revoked keys are rejected
+	_, resp = post(t, a, `mutation Revoke($id: ID!) { revokeApiKey(id: $id) { revokedAt } }`, map[string]interface{}{"id": issued.APIKey.ID}, admin)
+	assert.Empty(t, resp.Errors)
+	assert.NotContains(t, string(resp.Data["revokeApiKey"]), "null")
+
+	status, _ = post(t, a, me, nil, key)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestApp(t, r)
+	a.RateLimiter, err = ratelimit.New(ratelimit.Config{
+		Limit:          ratelimit.Limit{Rate: 1, Burst: 6},
+		ComplexityUnit: 100,
+	}, ratelimit.NewMemoryStore(), a.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.InitRoutes()
+
+	// Complexity 481, that is 5 tokens
+	query := `{ movies { title cast { name } } }`
+
+	status, resp := post(t, a, query, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Errors)
+
+	// The request token is taken, but not the 4 others
+	status, resp = post(t, a, query, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "rate limit exceeded", resp.Errors[0].Message)
+		assert.Equal(t, map[string]interface{}{"code": "RATE_LIMITED", "retryAfter": float64(4)}, resp.Errors[0].Extensions)
+	}
+
+	status, resp = post(t, a, query, nil, nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, float64(1), resp.Errors[0].Extensions["retryAfter"])
+	}
+
+	// Failed authentications are limited too
+	a = newAuthTestApp(t, nil)
+	a.RateLimiter, err = ratelimit.New(ratelimit.Config{
+		Limit:   ratelimit.Limit{Rate: 1, Burst: 60},
+		IPLimit: ratelimit.Limit{Rate: 1, Burst: 2},
+	}, ratelimit.NewMemoryStore(), a.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.InitRoutes()
+
+	invalid := http.Header{"Authorization": {"Bearer invalid"}}
+	for _, expected := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		status, _ = post(t, a, `{ me { subject } }`, nil, invalid)
+		assert.Equal(t, expected, status)
+	}
+}
+
+// postPersisted sends an automatic persisted query, with or without its text
+func postPersisted(t *testing.T, a *App, query string, hash string) *graphQLResponse {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":      query,
+		"extensions": map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/movies", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	a.Router.ServeHTTP(rec, req)
+
+	resp := &graphQLResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func errorCodes(resp *graphQLResponse) []interface{} {
+	codes := []interface{}{}
+	for _, e := range resp.Errors {
+		codes = append(codes, e.Extensions["code"])
+	}
+
+	return codes
+}
+
+func TestGraphQLPersistedQueries(t *testing.T) {
+	viper.Set("GRAPHQL_APQ_CACHE_SIZE", 10)
+	t.Cleanup(func() { viper.Set("GRAPHQL_APQ_CACHE_SIZE", 0) })
+
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApp(t, r)
+
+	query := `{ movies(title: "matrix") { title } }`
+	hash := persisted.Hash(query)
+
+	resp := postPersisted(t, a, "", hash)
+	assert.Equal(t, []interface{}{"PERSISTED_QUERY_NOT_FOUND"}, errorCodes(resp))
+
+	resp = postPersisted(t, a, query, hash)
+	assert.Empty(t, resp.Errors)
+	assert.Contains(t, string(resp.Data["movies"]), "The Matrix")
+
+	resp = postPersisted(t, a, "", hash)
+	assert.Empty(t, resp.Errors)
+	assert.Contains(t, string(resp.Data["movies"]), "The Matrix")
+}
+
+func TestGraphQLSafelist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	query := `{ movies(title: "matrix") { title } }`
+	if err := ioutil.WriteFile(filepath.Join(dir, "movies.graphql"), []byte(query+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApp(t, r)
+	a.Safelist, err = persisted.LoadSafelist(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.InitRoutes()
+
+	// Approved operations are accepted by hash or text
+	resp := postPersisted(t, a, "", persisted.Hash(query))
+	assert.Empty(t, resp.Errors)
+	assert.Contains(t, string(resp.Data["movies"]), "The Matrix")
+
+	status, resp := post(t, a, query, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Errors)
+
+	// Others are not, even when sent along with their hash
+	other := `{ movies(title: "matrix") { tagline } }`
+	resp = postPersisted(t, a, other, persisted.Hash(other))
+	assert.Equal(t, []interface{}{"OPERATION_NOT_ALLOWED"}, errorCodes(resp))
+
+	resp = postPersisted(t, a, "", persisted.Hash(other))
+	assert.Equal(t, []interface{}{"PERSISTED_QUERY_NOT_FOUND"}, errorCodes(resp))
+
+	_, resp = post(t, a, other, nil, nil)
+	assert.Equal(t, []interface{}{"OPERATION_NOT_ALLOWED"}, errorCodes(resp))
+}
+
+func TestGraphQLCache(t *testing.T) {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAuthTestApp(t, nil)
+	a.Cache = repository.NewCachingRepository(r, time.Minute, 100)
+	a.Service = service.NewService(a.Cache, a.Logger)
+	a.InitRoutes()
+
+	status, resp := post(t, a, `{ movies(title: "matrix") { title cast { name } } }`, nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Errors)
+	cached := a.Cache.Len()
+	assert.True(t, cached > 1)
+
+	_, resp = post(t, a, `mutation { purgeCache }`, nil, bearer(t, "admin", "ADMIN"))
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, strconv.Itoa(cached), string(resp.Data["purgeCache"]))
+	assert.Equal(t, 0, a.Cache.Len())
+}
+
+// get sends a query over GET, with optional headers
+func get(t *testing.T, a *App, query string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/movies?query="+url.QueryEscape(query), nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+
+	a.Router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestGraphQLHTTPCaching(t *testing.T) {
+	a := newAuthTestApp(t, nil)
+	query := `{ movies(title: "matrix") { title cast { name } } }`
+
+	rec := get(t, a, query, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "The Matrix")
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization, X-API-Key", rec.Header().Get("Vary"))
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rec = get(t, a, query, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+
+	// Responses to authenticated requests are private
+	rec = get(t, a, query, bearer(t, "viewer", "viewer"))
+	assert.Equal(t, "private, max-age=300", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	// Caller specific fields and errors are not cached
+	rec = get(t, a, `{ me { subject } }`, bearer(t, "viewer", "viewer"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	rec = get(t, a, `{ movies(title: "ma") { title } }`, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	// Mutations are not allowed over GET
+	rec = get(t, a, `mutation { purgeCache }`, nil)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Empty(t, rec.Header().Get("Cache-Control"))
+}
+
+// panickingRepository panics when finding a movie
+type panickingRepository struct {
+	repository.Repository
+}
+
+func (panickingRepository) FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error) {
+	panic("corrupted movie")
+}
+
+func TestGraphQLProfiles(t *testing.T) {
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newApp := func(profile string, r repository.Repository) *App {
+		a := newTestApp(t, r)
+		a.Profile = profiles[profile]
+		a.InitRoutes()
+		return a
+	}
+
+	// Internal errors are hidden, client errors are not
+	prod := newApp(ProfileProd, failingRepository{r})
+	header := http.Header{}
+	header.Set(logger.RequestIDHeader, "test-request")
+	runGoldenTests(t, prod, "testdata/graphql/repository_errors", "prod", header)
+
+	_, resp := post(t, newApp(ProfileProd, r), `{ movies(title: "ma") { title } }`, nil, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Contains(t, resp.Errors[0].Message, "'min' tag")
+	}
+
+	introspection := `{ __schema { queryType { name } } }`
+	_, resp = post(t, prod, introspection, nil, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "introspection disabled", resp.Errors[0].Message)
+	}
+	_, resp = post(t, newApp(ProfileStaging, r), introspection, nil, nil)
+	assert.Empty(t, resp.Errors)
+
+	rec := httptest.NewRecorder()
+	prod.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/playground", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Panics are returned with their stack trace in dev only
+	query := `{ movie(uuid: "1") { title } }`
+	_, resp = post(t, newApp(ProfileDev, panickingRepository{r}), query, nil, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "panic: corrupted movie", resp.Errors[0].Message)
+		assert.Equal(t, "INTERNAL_SERVER_ERROR", resp.Errors[0].Extensions["code"])
+		assert.NotEmpty(t, resp.Errors[0].Extensions["stacktrace"])
+	}
+
+	_, resp = post(t, newApp(ProfileProd, panickingRepository{r}), query, nil, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "internal server error", resp.Errors[0].Message)
+		assert.Equal(t, "INTERNAL_SERVER_ERROR", resp.Errors[0].Extensions["code"])
+		assert.Nil(t, resp.Errors[0].Extensions["stacktrace"])
+	}
+}
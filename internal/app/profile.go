@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// ProfileDev enables every feature exposing the API internals
+	ProfileDev = "dev"
+	// ProfileStaging enables tools (playground and introspection) but hides error details
+	ProfileStaging = "staging"
+	// ProfileProd locks everything down
+	ProfileProd = "prod"
+)
+
+// Profile controls the features exposing the API internals to clients
+type Profile struct {
+	Name string
+	// Playground mounts the GraphQL playground at /playground
+	Playground bool
+	// Introspection lets clients query the schema
+	Introspection bool
+	// ErrorDetails returns internal error messages and panic stack traces to clients
+	ErrorDetails bool
+}
+
+var profiles = map[string]Profile{
+	ProfileDev:     {Name: ProfileDev, Playground: true, Introspection: true, ErrorDetails: true},
+	ProfileStaging: {Name: ProfileStaging, Playground: true, Introspection: true},
+	ProfileProd:    {Name: ProfileProd},
+}
+
+// loadProfile returns the profile named by APP_ENV, with its features overridden by
+// GRAPHQL_PLAYGROUND, GRAPHQL_INTROSPECTION and GRAPHQL_ERROR_DETAILS when they are set.
+// It also returns the names of the overridden features.
+func loadProfile() (Profile, []string, error) {
+	name := viper.GetString("APP_ENV")
+	p, ok := profiles[name]
+	if !ok {
+		return p, nil, fmt.Errorf("invalid APP_ENV %q, expected %s, %s or %s", name, ProfileDev, ProfileStaging, ProfileProd)
+	}
+
+	overridden := []string{}
+	for key, feature := range map[string]*bool{
+		"GRAPHQL_PLAYGROUND":    &p.Playground,
+		"GRAPHQL_INTROSPECTION": &p.Introspection,
+		"GRAPHQL_ERROR_DETAILS": &p.ErrorDetails,
+	} {
+		if viper.IsSet(key) && viper.GetString(key) != "" {
+			*feature = viper.GetBool(key)
+			overridden = append(overridden, key)
+		}
+	}
+
+	sort.Strings(overridden)
+
+	return p, overridden, nil
+}
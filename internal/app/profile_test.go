@@ -0,0 +1,36 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProfile(t *testing.T) {
+	env := viper.GetString("APP_ENV")
+	t.Cleanup(func() {
+		viper.Set("APP_ENV", env)
+		viper.Set("GRAPHQL_PLAYGROUND", "")
+		viper.Set("GRAPHQL_ERROR_DETAILS", "")
+	})
+
+	viper.Set("APP_ENV", "production")
+	_, _, err := loadProfile()
+	assert.Error(t, err)
+
+	viper.Set("APP_ENV", ProfileProd)
+	p, overridden, err := loadProfile()
+	if assert.NoError(t, err) {
+		assert.Equal(t, profiles[ProfileProd], p)
+		assert.Empty(t, overridden)
+	}
+
+	viper.Set("GRAPHQL_PLAYGROUND", "true")
+	viper.Set("GRAPHQL_ERROR_DETAILS", "false")
+	p, overridden, err = loadProfile()
+	if assert.NoError(t, err) {
+		assert.Equal(t, Profile{Name: ProfileProd, Playground: true}, p)
+		assert.Equal(t, []string{"GRAPHQL_ERROR_DETAILS", "GRAPHQL_PLAYGROUND"}, overridden)
+	}
+}
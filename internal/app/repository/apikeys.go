@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+)
+
+var _ auth.KeyStore = &Neo4jRepository{}
+
+// apiKeyFields are the :ApiKey node properties returned by queries
+const apiKeyFields = "k.id, k.name, k.hash, k.scopes, k.createdAt, k.expiresAt, k.lastUsedAt, k.revokedAt"
+
+// CreateAPIKey stores a new key as an :ApiKey node. Times are stored as unix seconds.
+func (r *Neo4jRepository) CreateAPIKey(ctx context.Context, k *auth.APIKey) error {
+	query := `
+		create (k:ApiKey {id: $id, name: $name, hash: $hash, scopes: $scopes, createdAt: $createdAt, expiresAt: $expiresAt})
+	`
+
+	args := map[string]interface{}{
+		"id":        k.ID,
+		"name":      k.Name,
+		"hash":      k.Hash,
+		"scopes":    k.Scopes,
+		"createdAt": k.CreatedAt.Unix(),
+		"expiresAt": unixOrNil(k.ExpiresAt),
+	}
+
+	err := r.run(ctx, "CreateAPIKey", query, args, func(neo4j.Record) {})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot create API key", err)
+	}
+
+	return err
+}
+
+// FindAPIKey finds a key by its id
+func (r *Neo4jRepository) FindAPIKey(ctx context.Context, id string) (*auth.APIKey, error) {
+	query := `
+		match (k:ApiKey) where k.id = $id return ` + apiKeyFields + `
+	`
+
+	args := map[string]interface{}{
+		"id": id,
+	}
+
+	var key *auth.APIKey
+
+	err := r.run(ctx, "FindAPIKey", query, args, func(record neo4j.Record) {
+		key = parseAPIKey(record)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot find API key", err)
+	}
+
+	return key, err
+}
+
+// ListAPIKeys returns every key, ordered by creation time
+func (r *Neo4jRepository) ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	query := `
+		match (k:ApiKey) return ` + apiKeyFields + ` order by k.createdAt, k.id
+	`
+
+	keys := []*auth.APIKey{}
+
+	err := r.run(ctx, "ListAPIKeys", query, nil, func(record neo4j.Record) {
+		keys = append(keys, parseAPIKey(record))
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot list API keys", err)
+	}
+
+	return keys, err
+}
+
+// TouchAPIKey stores the last use time of a key
+func (r *Neo4jRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	query := `
+		match (k:ApiKey) where k.id = $id set k.lastUsedAt = $lastUsedAt
+	`
+
+	args := map[string]interface{}{
+		"id":         id,
+		"lastUsedAt": at.Unix(),
+	}
+
+	err := r.run(ctx, "TouchAPIKey", query, args, func(neo4j.Record) {})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot update API key last use", err)
+	}
+
+	return err
+}
+
+// RevokeAPIKey stores the revocation time of a key, unless it is already revoked
+func (r *Neo4jRepository) RevokeAPIKey(ctx context.Context, id string, at time.Time) (*auth.APIKey, error) {
+	query := `
+		match (k:ApiKey) where k.id = $id set k.revokedAt = coalesce(k.revokedAt, $revokedAt) return ` + apiKeyFields + `
+	`
+
+	args := map[string]interface{}{
+		"id":        id,
+		"revokedAt": at.Unix(),
+	}
+
+	var key *auth.APIKey
+
+	err := r.run(ctx, "RevokeAPIKey", query, args, func(record neo4j.Record) {
+		key = parseAPIKey(record)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot revoke API key", err)
+	}
+
+	return key, err
+}
+
+func parseAPIKey(record neo4j.Record) *auth.APIKey {
+	key := &auth.APIKey{Scopes: []string{}}
+
+	if v, ok := record.Get("k.id"); ok && v != nil {
+		key.ID = v.(string)
+	}
+	if v, ok := record.Get("k.name"); ok && v != nil {
+		key.Name = v.(string)
+	}
+	if v, ok := record.Get("k.hash"); ok && v != nil {
+		key.Hash = v.(string)
+	}
+	if v, ok := record.Get("k.scopes"); ok && v != nil {
+		for _, s := range v.([]interface{}) {
+			key.Scopes = append(key.Scopes, s.(string))
+		}
+	}
+	if t := timeOrNil(record, "k.createdAt"); t != nil {
+		key.CreatedAt = *t
+	}
+	key.ExpiresAt = timeOrNil(record, "k.expiresAt")
+	key.LastUsedAt = timeOrNil(record, "k.lastUsedAt")
+	key.RevokedAt = timeOrNil(record, "k.revokedAt")
+
+	return key
+}
+
+func unixOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return t.Unix()
+}
+
+func timeOrNil(record neo4j.Record, key string) *time.Time {
+	v, ok := record.Get(key)
+	if !ok || v == nil {
+		return nil
+	}
+
+	t := time.Unix(v.(int64), 0).UTC()
+	return &t
+}
@@ -0,0 +1,64 @@
+//go:build !noseabolt
+// +build !noseabolt
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeo4jAPIKeys(t *testing.T) {
+	r, server, _ := stubRepository(t)
+
+	created := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	used := created.Add(time.Hour)
+
+	server.Expect("create (k:ApiKey {id: $id, name: $name, hash: $hash, scopes: $scopes, createdAt: $createdAt, expiresAt: $expiresAt})", map[string]interface{}{
+		"id":        "0123456789abcdef",
+		"name":      "batch",
+		"hash":      "c0ffee",
+		"scopes":    []string{"viewer"},
+		"createdAt": created.Unix(),
+		"expiresAt": nil,
+	})
+	server.Expect("match (k:ApiKey) where k.id = $id return "+apiKeyFields, map[string]interface{}{"id": "0123456789abcdef"}).
+		Fields("k.id", "k.name", "k.hash", "k.scopes", "k.createdAt", "k.expiresAt", "k.lastUsedAt", "k.revokedAt").
+		Record("0123456789abcdef", "batch", "c0ffee", []string{"viewer"}, created.Unix(), nil, used.Unix(), nil)
+	server.Expect("match (k:ApiKey) where k.id = $id return "+apiKeyFields, map[string]interface{}{"id": "fedcba9876543210"}).
+		Fields("k.id", "k.name", "k.hash", "k.scopes", "k.createdAt", "k.expiresAt", "k.lastUsedAt", "k.revokedAt")
+	server.Expect("match (k:ApiKey) where k.id = $id set k.lastUsedAt = $lastUsedAt", map[string]interface{}{
+		"id":         "0123456789abcdef",
+		"lastUsedAt": used.Unix(),
+	})
+	server.Expect("match (k:ApiKey) where k.id = $id set k.revokedAt = coalesce(k.revokedAt, $revokedAt) return "+apiKeyFields, map[string]interface{}{
+		"id":        "0123456789abcdef",
+		"revokedAt": used.Unix(),
+	}).
+		Fields("k.id", "k.name", "k.hash", "k.scopes", "k.createdAt", "k.expiresAt", "k.lastUsedAt", "k.revokedAt").
+		Record("0123456789abcdef", "batch", "c0ffee", []string{"viewer"}, created.Unix(), nil, used.Unix(), used.Unix())
+
+	key := &auth.APIKey{ID: "0123456789abcdef", Name: "batch", Hash: "c0ffee", Scopes: []string{"viewer"}, CreatedAt: created}
+	assert.Nil(t, r.CreateAPIKey(context.Background(), key))
+
+	found, err := r.FindAPIKey(context.Background(), "0123456789abcdef")
+	assert.Nil(t, err)
+	key.LastUsedAt = &used
+	assert.Equal(t, key, found)
+
+	found, err = r.FindAPIKey(context.Background(), "fedcba9876543210")
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	assert.Nil(t, r.TouchAPIKey(context.Background(), "0123456789abcdef", used))
+
+	revoked, err := r.RevokeAPIKey(context.Background(), "0123456789abcdef", used)
+	assert.Nil(t, err)
+	key.RevokedAt = &used
+	assert.Equal(t, key, revoked)
+	assert.Empty(t, server.Unexpected())
+}
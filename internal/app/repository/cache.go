@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/pkg/metrics"
+)
+
+// CachingRepository decorates a repository, caching the results of its methods for
+// a TTL. It holds up to a number of results, evicting the least recently used first.
+// Errors are not cached. Paths changing the movie graph must call Purge.
+type CachingRepository struct {
+	repository Repository
+	ttl        time.Duration
+	size       int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+var _ Repository = &CachingRepository{}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewCachingRepository caches the results of r for ttl, holding up to size results
+func NewCachingRepository(r Repository, ttl time.Duration, size int) *CachingRepository {
+	return &CachingRepository{
+		repository: r,
+		ttl:        ttl,
+		size:       size,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+// FindMovieByUUID finds a movie by its uuid
+func (c *CachingRepository) FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error) {
+	v, err := c.get("FindMovieByUUID", cacheKey(uuid), func() (interface{}, error) {
+		return c.repository.FindMovieByUUID(ctx, uuid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*models.Movie), nil
+}
+
+// FindMovies finds movies by title and actor
+func (c *CachingRepository) FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
+	v, err := c.get("FindMovies", cacheKey(optional(title), optional(actor), optionalInt(limit)), func() (interface{}, error) {
+		return c.repository.FindMovies(ctx, title, actor, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*models.Movie), nil
+}
+
+// FindMovieParticipationsByPersonUUID finds the movies a person participated in
+func (c *CachingRepository) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error) {
+	v, err := c.get("FindMovieParticipationsByPersonUUID", cacheKey(uuid, optionalInt(limit)), func() (interface{}, error) {
+		return c.repository.FindMovieParticipationsByPersonUUID(ctx, uuid, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*model.Participation), nil
+}
+
+// FindPersonByMovieUUID finds the people related to a movie by role
+func (c *CachingRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error) {
+	v, err := c.get("FindPersonByMovieUUID", cacheKey(role, uuid, optionalInt(limit)), func() (interface{}, error) {
+		return c.repository.FindPersonByMovieUUID(ctx, role, uuid, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*models.Person), nil
+}
+
+// Purge drops every cached result, returning how many there were
+func (c *CachingRepository) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.lru.Len()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	metrics.SetCacheEntries(0)
+
+	return n
+}
+
+// Len returns the number of cached results, expired ones included
+func (c *CachingRepository) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// get returns the cached result of method for key, or the result of find, which is
+// cached unless it fails. Concurrent misses for the same key all call find.
+func (c *CachingRepository) get(method string, key string, find func() (interface{}, error)) (interface{}, error) {
+	key = method + key
+
+	if v, ok := c.lookup(key); ok {
+		metrics.CacheLookup(method, true)
+		return v, nil
+	}
+	metrics.CacheLookup(method, false)
+
+	v, err := find()
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(key, v)
+
+	return v, nil
+}
+
+func (c *CachingRepository) lookup(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		metrics.SetCacheEntries(c.lru.Len())
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return entry.value, true
+}
+
+func (c *CachingRepository) add(key string, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, value: v, expires: c.now().Add(c.ttl)})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+
+	metrics.SetCacheEntries(c.lru.Len())
+}
+
+func (c *CachingRepository) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// cacheKey joins method arguments, which can be empty, in an unambiguous key
+func cacheKey(args ...string) string {
+	key := ""
+	for _, a := range args {
+		key += "\x00" + a
+	}
+
+	return key
+}
+
+// optional returns the value of an optional argument, marking nil ones apart from
+// empty strings
+func optional(s *string) string {
+	if s == nil {
+		return "\x01"
+	}
+
+	return *s
+}
+
+// optionalInt returns the value of an optional int argument, marking nil ones apart
+func optionalInt(i *int) string {
+	if i == nil {
+		return "\x01"
+	}
+
+	return strconv.Itoa(*i)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingRepository counts the calls to FindPersonByMovieUUID and FindMovies,
+// failing when fail is set
+type countingRepository struct {
+	Repository
+	calls int
+	fail  bool
+}
+
+func (r *countingRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error) {
+	r.calls++
+	if r.fail {
+		return nil, errors.New("Neo4j unavailable")
+	}
+
+	return r.Repository.FindPersonByMovieUUID(ctx, role, uuid, limit)
+}
+
+func (r *countingRepository) FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
+	r.calls++
+
+	return r.Repository.FindMovies(ctx, title, actor, limit)
+}
+
+func newTestCache(t *testing.T, ttl time.Duration, size int) (*CachingRepository, *countingRepository, *time.Time) {
+	r, err := NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counting := &countingRepository{Repository: r}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCachingRepository(counting, ttl, size)
+	c.now = func() time.Time { return now }
+
+	return c, counting, &now
+}
+
+func TestCachingRepository(t *testing.T) {
+	ctx := context.Background()
+	c, r, now := newTestCache(t, time.Minute, 10)
+	matrix := findMovieByTitle(t, r.Repository.(*InMemoryRepository), "The Matrix")
+
+	cast, err := c.FindPersonByMovieUUID(ctx, "ACTED_IN", matrix, nil)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, cast)
+
+	cached, err := c.FindPersonByMovieUUID(ctx, "ACTED_IN", matrix, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, cast, cached)
+	assert.Equal(t, 1, r.calls)
+
+	// Arguments are part of keys
+	directors, _ := c.FindPersonByMovieUUID(ctx, "DIRECTED", matrix, nil)
+	assert.NotEqual(t, cast, directors)
+	assert.Equal(t, 2, r.calls)
+
+	// Expired results are found again
+	*now = now.Add(time.Minute)
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", matrix, nil)
+	assert.Equal(t, 3, r.calls)
+
+	assert.Equal(t, 2, c.Purge())
+	assert.Equal(t, 0, c.Len())
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", matrix, nil)
+	assert.Equal(t, 4, r.calls)
+}
+
+func TestCachingRepositoryOptionalArguments(t *testing.T) {
+	ctx := context.Background()
+	c, r, _ := newTestCache(t, time.Minute, 10)
+
+	all, _ := c.FindMovies(ctx, nil, nil, nil)
+	empty, _ := c.FindMovies(ctx, StringPtr(""), nil, nil)
+	byActor, _ := c.FindMovies(ctx, nil, StringPtr("keanu"), nil)
+	assert.Equal(t, 3, r.calls)
+	assert.NotEqual(t, len(all), len(byActor))
+
+	cached, _ := c.FindMovies(ctx, StringPtr(""), nil, nil)
+	assert.Equal(t, empty, cached)
+	assert.Equal(t, 3, r.calls)
+
+	// Limits are part of keys, nil apart from zero
+	limited, _ := c.FindMovies(ctx, nil, nil, IntPtr(2))
+	none, _ := c.FindMovies(ctx, nil, nil, IntPtr(0))
+	assert.Len(t, limited, 2)
+	assert.Empty(t, none)
+	assert.Equal(t, 5, r.calls)
+}
+
+func TestCachingRepositoryEviction(t *testing.T) {
+	ctx := context.Background()
+	c, r, _ := newTestCache(t, time.Minute, 2)
+
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "1", nil)
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "2", nil)
+	// 1 is now the most recently used
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "1", nil)
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "3", nil)
+	assert.Equal(t, 3, r.calls)
+	assert.Equal(t, 2, c.Len())
+
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "1", nil)
+	assert.Equal(t, 3, r.calls)
+	_, _ = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "2", nil)
+	assert.Equal(t, 4, r.calls)
+}
+
+func TestCachingRepositoryErrors(t *testing.T) {
+	ctx := context.Background()
+	c, r, _ := newTestCache(t, time.Minute, 10)
+
+	r.fail = true
+	_, err := c.FindPersonByMovieUUID(ctx, "ACTED_IN", "1", nil)
+	assert.NotNil(t, err)
+
+	r.fail = false
+	_, err = c.FindPersonByMovieUUID(ctx, "ACTED_IN", "1", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r.calls)
+}
@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/internal/app/repository"
+	"github.com/charlysan/goneo4jgql/internal/app/repository/repositorytest"
+	"github.com/spf13/viper"
+)
+
+func TestInMemoryRepositoryConformance(t *testing.T) {
+	r, err := repository.NewInMemoryRepository("../../../neo4j/import/movies.cypher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositorytest.Run(t, r)
+}
+
+// TestNeo4jRepositoryConformance needs a Neo4j server with the movies dataset loaded.
+// It runs when NEO4J_CONFORMANCE is set, using the same NEO4J_* env vars as the API, e.g.
+//   NEO4J_CONFORMANCE=1 NEO4J_HOST=localhost go test ./internal/app/repository/...
+func TestNeo4jRepositoryConformance(t *testing.T) {
+	if os.Getenv("NEO4J_CONFORMANCE") == "" {
+		t.Skip("NEO4J_CONFORMANCE not set")
+	}
+
+	viper.AutomaticEnv()
+	viper.SetDefault("NEO4J_HOST", "localhost")
+	viper.SetDefault("NEO4J_PORT", "7687")
+	viper.SetDefault("NEO4J_USER", "neo4j")
+	viper.SetDefault("NEO4J_PASS", "test")
+	viper.SetDefault("NEO4J_PROTO", "bolt")
+	viper.SetDefault("NEO4J_MAX_POOL_SIZE", 10)
+	viper.SetDefault("NEO4J_SLOW_QUERY_THRESHOLD", "-1s")
+
+	driver, err := repository.NewNeo4jConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	repositorytest.Run(t, &repository.Neo4jRepository{Connection: driver})
+}
+
+func TestCachingRepositoryConformance(t *testing.T) {
+	r, err := repository.NewInMemoryRepository("../../../neo4j/import/movies.cypher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositorytest.Run(t, repository.NewCachingRepository(r, time.Minute, 100))
+}
@@ -0,0 +1,443 @@
+package repository
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+)
+
+// movieGraph holds the movies and people of a fixture, and the relationships between them
+type movieGraph struct {
+	Movies        []*models.Movie `json:"movies"`
+	People        []*models.Person `json:"people"`
+	Relationships []relationship   `json:"relationships"`
+}
+
+// relationship links a person and a movie. Relationships are directed from the person
+// to the movie (e.g. ACTED_IN) unless Reversed is set.
+type relationship struct {
+	Person   string `json:"person"`
+	Movie    string `json:"movie"`
+	Type     string `json:"type"`
+	Reversed bool   `json:"reversed,omitempty"`
+}
+
+// loadJSONFixture reads a graph using the movieGraph JSON layout, e.g.
+//   {"movies": [{"uuid": "...", "title": "...", "released": 1999, "tagline": "..."}],
+//    "people": [{"uuid": "...", "name": "...", "born": 1964}],
+//    "relationships": [{"person": "<person uuid>", "movie": "<movie uuid>", "type": "ACTED_IN"}]}
+func loadJSONFixture(r io.Reader) (*movieGraph, error) {
+	g := &movieGraph{}
+	if err := json.NewDecoder(r).Decode(g); err != nil {
+		return nil, err
+	}
+
+	for _, p := range g.People {
+		p.Role = nil
+	}
+
+	return g, nil
+}
+
+// cypherNode is a node created by a cypher fixture
+type cypherNode struct {
+	label string
+	props map[string]interface{}
+}
+
+// cypherParser reads the CREATE clauses of a cypher script (such as neo4j/import/movies.cypher).
+// It supports nodes with a single label and property maps, and relationships between
+// previously created nodes. Parsing stops at the first clause that is not a CREATE.
+type cypherParser struct {
+	src   string
+	pos   int
+	nodes map[string]*cypherNode
+	order []string
+	rels  []cypherRelationship
+}
+
+type cypherRelationship struct {
+	from, to, kind string
+}
+
+// loadCypherFixture builds a graph from the CREATE clauses of a cypher script.
+// Nodes without an uuid property get one derived from their label and title or name,
+// so ids are stable across loads.
+func loadCypherFixture(r io.Reader) (*movieGraph, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cypherParser{src: string(src), nodes: map[string]*cypherNode{}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	g := &movieGraph{}
+	uuids := map[string]string{}
+
+	for _, name := range p.order {
+		n := p.nodes[name]
+		switch n.label {
+		case "Movie":
+			m := &models.Movie{
+				Title:    stringProp(n.props, "title"),
+				Tagline:  stringProp(n.props, "tagline"),
+				Released: intProp(n.props, "released"),
+			}
+			m.UUID = uuidProp(n.props, "Movie:"+m.Title)
+			uuids[name] = m.UUID
+			g.Movies = append(g.Movies, m)
+		case "Person":
+			person := &models.Person{
+				Name: stringProp(n.props, "name"),
+				Born: intProp(n.props, "born"),
+			}
+			person.UUID = uuidProp(n.props, "Person:"+person.Name)
+			uuids[name] = person.UUID
+			g.People = append(g.People, person)
+		}
+	}
+
+	for _, rel := range p.rels {
+		from, to := p.nodes[rel.from], p.nodes[rel.to]
+		switch {
+		case from.label == "Person" && to.label == "Movie":
+			g.Relationships = append(g.Relationships, relationship{Person: uuids[rel.from], Movie: uuids[rel.to], Type: rel.kind})
+		case from.label == "Movie" && to.label == "Person":
+			g.Relationships = append(g.Relationships, relationship{Person: uuids[rel.to], Movie: uuids[rel.from], Type: rel.kind, Reversed: true})
+		}
+	}
+
+	return g, nil
+}
+
+func (p *cypherParser) parse() error {
+	for {
+		p.skipSpace()
+		if p.eof() || !p.keyword("CREATE") {
+			return nil
+		}
+
+		// e.g. CREATE CONSTRAINT ON ...
+		p.skipSpace()
+		if p.peek() != '(' {
+			return nil
+		}
+
+		for {
+			if err := p.pattern(); err != nil {
+				return err
+			}
+
+			p.skipSpace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+}
+
+// pattern reads either a node, e.g. (Keanu:Person {name:'Keanu Reeves'}), or a
+// directed relationship, e.g. (Keanu)-[:ACTED_IN {roles:['Neo']}]->(TheMatrix)
+func (p *cypherParser) pattern() error {
+	from, err := p.node()
+	if err != nil {
+		return err
+	}
+
+	p.skipSpace()
+	reversed := false
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "-["):
+		p.pos += 2
+	case strings.HasPrefix(p.src[p.pos:], "<-["):
+		p.pos += 3
+		reversed = true
+	default:
+		return nil
+	}
+
+	if err := p.expect(':'); err != nil {
+		return err
+	}
+	kind := p.identifier()
+
+	p.skipSpace()
+	if p.peek() == '{' {
+		if _, err := p.mapLiteral(); err != nil {
+			return err
+		}
+	}
+
+	p.skipSpace()
+	end := "]->"
+	if reversed {
+		end = "]-"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], end) {
+		return p.errorf("expected %q", end)
+	}
+	p.pos += len(end)
+
+	to, err := p.node()
+	if err != nil {
+		return err
+	}
+
+	if p.nodes[from] == nil || p.nodes[to] == nil {
+		return p.errorf("unknown node in relationship %s -> %s", from, to)
+	}
+
+	if reversed {
+		from, to = to, from
+	}
+	p.rels = append(p.rels, cypherRelationship{from: from, to: to, kind: kind})
+
+	return nil
+}
+
+// node reads a node pattern, registering it when it has a label, and returns its variable name
+func (p *cypherParser) node() (string, error) {
+	p.skipSpace()
+	if err := p.expect('('); err != nil {
+		return "", err
+	}
+
+	name := p.identifier()
+
+	p.skipSpace()
+	if p.peek() == ':' {
+		p.pos++
+		n := &cypherNode{label: p.identifier(), props: map[string]interface{}{}}
+
+		p.skipSpace()
+		if p.peek() == '{' {
+			props, err := p.mapLiteral()
+			if err != nil {
+				return "", err
+			}
+			n.props = props
+		}
+
+		if _, ok := p.nodes[name]; !ok {
+			p.order = append(p.order, name)
+		}
+		p.nodes[name] = n
+	}
+
+	p.skipSpace()
+	if err := p.expect(')'); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// mapLiteral reads a property map, e.g. {name:'Keanu Reeves', born:1964}
+func (p *cypherParser) mapLiteral() (map[string]interface{}, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{}
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return props, nil
+		}
+
+		key := p.identifier()
+		if key == "" {
+			return nil, p.errorf("expected property name")
+		}
+
+		p.skipSpace()
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		props[key] = value
+
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+}
+
+// value reads a string, integer or list literal
+func (p *cypherParser) value() (interface{}, error) {
+	p.skipSpace()
+
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		return p.stringLiteral()
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for {
+			p.skipSpace()
+			if p.peek() == ']' {
+				p.pos++
+				return list, nil
+			}
+
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+
+			p.skipSpace()
+			if p.peek() == ',' {
+				p.pos++
+			}
+		}
+	case c == '-' || unicode.IsDigit(rune(c)):
+		start := p.pos
+		p.pos++
+		for !p.eof() && unicode.IsDigit(rune(p.peek())) {
+			p.pos++
+		}
+
+		return strconv.ParseInt(p.src[start:p.pos], 10, 64)
+	default:
+		return nil, p.errorf("unsupported value")
+	}
+}
+
+func (p *cypherParser) stringLiteral() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+
+		switch c {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				break
+			}
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *cypherParser) identifier() string {
+	start := p.pos
+	for !p.eof() {
+		c := rune(p.src[p.pos])
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			break
+		}
+		p.pos++
+	}
+
+	return p.src[start:p.pos]
+}
+
+// keyword consumes word (case insensitive) if it is next in the input
+func (p *cypherParser) keyword(word string) bool {
+	end := p.pos + len(word)
+	if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], word) {
+		return false
+	}
+
+	if end < len(p.src) && (unicode.IsLetter(rune(p.src[end])) || p.src[end] == '_') {
+		return false
+	}
+
+	p.pos = end
+	return true
+}
+
+// skipSpace skips white space and // comments
+func (p *cypherParser) skipSpace() {
+	for !p.eof() {
+		switch {
+		case unicode.IsSpace(rune(p.src[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			if nl := strings.IndexByte(p.src[p.pos:], '\n'); nl >= 0 {
+				p.pos += nl + 1
+			} else {
+				p.pos = len(p.src)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *cypherParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+
+	return nil
+}
+
+func (p *cypherParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.src[p.pos]
+}
+
+func (p *cypherParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *cypherParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("Invalid cypher fixture (line %d): %s", line, fmt.Sprintf(format, args...))
+}
+
+func stringProp(props map[string]interface{}, key string) string {
+	s, _ := props[key].(string)
+	return s
+}
+
+func intProp(props map[string]interface{}, key string) int64 {
+	i, _ := props[key].(int64)
+	return i
+}
+
+// uuidProp returns the uuid property, or a name based (version 5 like) uuid derived from seed
+func uuidProp(props map[string]interface{}, seed string) string {
+	if uuid := stringProp(props, "uuid"); uuid != "" {
+		return uuid
+	}
+
+	h := sha1.Sum([]byte(seed))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
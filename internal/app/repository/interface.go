@@ -7,12 +7,13 @@ import (
 	"github.com/charlysan/goneo4jgql/internal/app/models"
 )
 
-// Repository definition for repository
+// Repository definition for repository. List methods return at most limit
+// results, or all of them when limit is nil.
 type Repository interface {
 	// Movie
 	FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error)
-	FindMovies(ctx context.Context, title *string, actor *string) ([]*models.Movie, error)
-	FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string) ([]*model.Participation, error)
+	FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error)
+	FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error)
 	// Person
-	FindPersonByMovieUUID(ctx context.Context, role string, uuid string) ([]*models.Person, error)
+	FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error)
 }
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+)
+
+const (
+	// BackendNeo4j selects the Neo4j repository
+	BackendNeo4j = "neo4j"
+	// BackendMemory selects the in-memory repository
+	BackendMemory = "memory"
+)
+
+// InMemoryRepository is a read only repository holding the whole movie graph in memory.
+// It returns the same results as Neo4jRepository for the same data, so it can be used
+// for tests and demos without a database.
+type InMemoryRepository struct {
+	movies        []*models.Movie
+	people        []*models.Person
+	relationships []relationship
+	moviesByUUID  map[string]*models.Movie
+	peopleByUUID  map[string]*models.Person
+}
+
+// NewInMemoryRepository loads a fixture file: a cypher script with CREATE clauses
+// (e.g. neo4j/import/movies.cypher) or, when its extension is .json, a JSON graph
+func NewInMemoryRepository(fixture string) (*InMemoryRepository, error) {
+	f, err := os.Open(fixture)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var g *movieGraph
+	if strings.EqualFold(filepath.Ext(fixture), ".json") {
+		g, err = loadJSONFixture(f)
+	} else {
+		g, err = loadCypherFixture(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load fixture %s: %v", fixture, err)
+	}
+
+	return newInMemoryRepository(g)
+}
+
+func newInMemoryRepository(g *movieGraph) (*InMemoryRepository, error) {
+	r := &InMemoryRepository{
+		movies:        g.Movies,
+		people:        g.People,
+		relationships: g.Relationships,
+		moviesByUUID:  map[string]*models.Movie{},
+		peopleByUUID:  map[string]*models.Person{},
+	}
+
+	for _, m := range g.Movies {
+		r.moviesByUUID[m.UUID] = m
+	}
+
+	for _, p := range g.People {
+		r.peopleByUUID[p.UUID] = p
+	}
+
+	for _, rel := range g.Relationships {
+		if r.moviesByUUID[rel.Movie] == nil || r.peopleByUUID[rel.Person] == nil {
+			return nil, fmt.Errorf("Invalid relationship %s between %s and %s", rel.Type, rel.Person, rel.Movie)
+		}
+	}
+
+	return r, nil
+}
+
+// Size returns the number of movies, people and relationships loaded
+func (r *InMemoryRepository) Size() (movies int, people int, relationships int) {
+	return len(r.movies), len(r.people), len(r.relationships)
+}
+
+// FindMovieByUUID finds a movie by its uuid. An empty movie is returned when there is no match.
+func (r *InMemoryRepository) FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error) {
+	movie := models.Movie{}
+	if m, ok := r.moviesByUUID[uuid]; ok {
+		movie = *m
+	}
+
+	return &movie, nil
+}
+
+// FindMovies finds movies by title and actor (case insensitive substrings), ordered by title.
+// As with Neo4j, a movie is returned once for every matching actor when filtering by actor.
+func (r *InMemoryRepository) FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
+	var movies []*models.Movie
+
+	matchesTitle := func(m *models.Movie) bool {
+		return title == nil || strings.Contains(strings.ToLower(m.Title), strings.ToLower(*title))
+	}
+
+	if actor == nil {
+		for _, m := range r.movies {
+			if matchesTitle(m) {
+				movie := *m
+				movies = append(movies, &movie)
+			}
+		}
+
+		sortMovies(movies)
+		return movies[:limitLen(len(movies), limit)], nil
+	}
+
+	for _, rel := range r.relationships {
+		if rel.Type != "ACTED_IN" {
+			continue
+		}
+
+		m := r.moviesByUUID[rel.Movie]
+		p := r.peopleByUUID[rel.Person]
+		if matchesTitle(m) && strings.Contains(strings.ToLower(p.Name), strings.ToLower(*actor)) {
+			movie := *m
+			movies = append(movies, &movie)
+		}
+	}
+
+	sortMovies(movies)
+	return movies[:limitLen(len(movies), limit)], nil
+}
+
+// FindMovieParticipationsByPersonUUID finds the movies a person is related to, whatever the
+// relationship, ordered by movie title and role
+func (r *InMemoryRepository) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error) {
+	var participations []*model.Participation
+
+	for _, rel := range r.relationships {
+		if rel.Person != uuid {
+			continue
+		}
+
+		movie := *r.moviesByUUID[rel.Movie]
+		participations = append(participations, &model.Participation{
+			Movie: &movie,
+			Role:  rel.Type,
+		})
+	}
+
+	sort.SliceStable(participations, func(i, j int) bool {
+		if participations[i].Movie.Title != participations[j].Movie.Title {
+			return participations[i].Movie.Title < participations[j].Movie.Title
+		}
+		return participations[i].Role < participations[j].Role
+	})
+
+	return participations[:limitLen(len(participations), limit)], nil
+}
+
+// FindPersonByMovieUUID finds people (actors, directors, writers) by movie uuid, ordered by name
+func (r *InMemoryRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error) {
+	var people []*models.Person
+
+	for _, rel := range r.relationships {
+		if rel.Movie != uuid || rel.Type != role || rel.Reversed {
+			continue
+		}
+
+		person := *r.peopleByUUID[rel.Person]
+		person.Role = StringPtr(role)
+		people = append(people, &person)
+	}
+
+	sort.SliceStable(people, func(i, j int) bool {
+		return people[i].Name < people[j].Name
+	})
+
+	return people[:limitLen(len(people), limit)], nil
+}
+
+func sortMovies(movies []*models.Movie) {
+	sort.SliceStable(movies, func(i, j int) bool {
+		return movies[i].Title < movies[j].Title
+	})
+}
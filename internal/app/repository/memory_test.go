@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const moviesFixture = "../../../neo4j/import/movies.cypher"
+
+func findMovieByTitle(t *testing.T, r *InMemoryRepository, title string) string {
+	movies, err := r.FindMovies(context.Background(), StringPtr(title), nil, nil)
+	assert.Nil(t, err)
+	for _, m := range movies {
+		if m.Title == title {
+			return m.UUID
+		}
+	}
+
+	t.Fatalf("movie %q not found", title)
+	return ""
+}
+
+func TestInMemoryRepositoryCypherFixture(t *testing.T) {
+	r, err := NewInMemoryRepository(moviesFixture)
+	assert.Nil(t, err)
+
+	movies, people, relationships := r.Size()
+	assert.Equal(t, 38, movies)
+	assert.Equal(t, 133, people)
+	assert.Equal(t, 250, relationships)
+
+	ctx := context.Background()
+
+	// uuids are stable across loads
+	again, _ := NewInMemoryRepository(moviesFixture)
+	assert.Equal(t, findMovieByTitle(t, r, "The Matrix"), findMovieByTitle(t, again, "The Matrix"))
+
+	matrix, err := r.FindMovieByUUID(ctx, findMovieByTitle(t, r, "The Matrix"))
+	assert.Nil(t, err)
+	assert.Equal(t, "The Matrix", matrix.Title)
+	assert.Equal(t, int64(1999), matrix.Released)
+	assert.Equal(t, "Welcome to the Real World", matrix.Tagline)
+
+	missing, err := r.FindMovieByUUID(ctx, "missing")
+	assert.Nil(t, err)
+	assert.Equal(t, "", missing.UUID)
+
+	found, err := r.FindMovies(ctx, StringPtr("MATRIX"), nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, found, 3)
+
+	found, err = r.FindMovies(ctx, StringPtr("matrix"), StringPtr("keanu"), nil)
+	assert.Nil(t, err)
+	assert.Len(t, found, 3)
+
+	found, err = r.FindMovies(ctx, nil, StringPtr("nobody"), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	directors, err := r.FindPersonByMovieUUID(ctx, "DIRECTED", matrix.UUID, nil)
+	assert.Nil(t, err)
+	assert.Len(t, directors, 2)
+	for _, d := range directors {
+		assert.True(t, strings.HasSuffix(d.Name, "Wachowski"), d.Name)
+		assert.Equal(t, "DIRECTED", *d.Role)
+	}
+
+	cast, _ := r.FindPersonByMovieUUID(ctx, "ACTED_IN", matrix.UUID, nil)
+	assert.Len(t, cast, 5)
+
+	keanu := ""
+	for _, p := range cast {
+		if p.Name == "Keanu Reeves" {
+			keanu = p.UUID
+			assert.Equal(t, int64(1964), p.Born)
+		}
+	}
+
+	participations, err := r.FindMovieParticipationsByPersonUUID(ctx, keanu, nil)
+	assert.Nil(t, err)
+	assert.Len(t, participations, 7)
+	for _, p := range participations {
+		assert.Equal(t, "ACTED_IN", p.Role)
+	}
+}
+
+func TestInMemoryRepositoryJSONFixture(t *testing.T) {
+	g, err := loadJSONFixture(strings.NewReader(`{
+		"movies": [{"uuid": "m1", "title": "Top Gun", "released": 1986}],
+		"people": [{"uuid": "p1", "name": "Tom Cruise", "born": 1962}, {"uuid": "p2", "name": "Tony Scott"}],
+		"relationships": [
+			{"person": "p1", "movie": "m1", "type": "ACTED_IN"},
+			{"person": "p2", "movie": "m1", "type": "DIRECTED"}
+		]
+	}`))
+	assert.Nil(t, err)
+
+	r, err := newInMemoryRepository(g)
+	assert.Nil(t, err)
+
+	people, _ := r.FindPersonByMovieUUID(context.Background(), "DIRECTED", "m1", nil)
+	assert.Len(t, people, 1)
+	assert.Equal(t, "Tony Scott", people[0].Name)
+
+	g.Relationships = append(g.Relationships, relationship{Person: "p3", Movie: "m1", Type: "WROTE"})
+	_, err = newInMemoryRepository(g)
+	assert.NotNil(t, err)
+}
+
+func TestLoadCypherFixture(t *testing.T) {
+	g, err := loadCypherFixture(strings.NewReader(`
+		CREATE (TopGun:Movie {title:"Top Gun", released:1986, tagline:'I feel the need, the need for speed.'})
+		CREATE (TomC:Person {name:'Tom Cruise', born:1962}), (TonyS:Person {name:'Tony Scott'})
+		CREATE
+		(TomC)-[:ACTED_IN {roles:['Maverick']}]->(TopGun),
+		(TopGun)<-[:DIRECTED]-(TonyS)
+		// anything after the CREATE clauses is ignored
+		WITH TomC as a
+		MATCH (a) RETURN a;
+	`))
+	assert.Nil(t, err)
+
+	assert.Len(t, g.Movies, 1)
+	assert.Equal(t, "I feel the need, the need for speed.", g.Movies[0].Tagline)
+	assert.Len(t, g.People, 2)
+	assert.Equal(t, int64(0), g.People[1].Born)
+	assert.Equal(t, []relationship{
+		{Person: g.People[0].UUID, Movie: g.Movies[0].UUID, Type: "ACTED_IN"},
+		{Person: g.People[1].UUID, Movie: g.Movies[0].UUID, Type: "DIRECTED"},
+	}, g.Relationships)
+
+	_, err = loadCypherFixture(strings.NewReader(`CREATE (a)-[:ACTED_IN]->(b)`))
+	assert.NotNil(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
 	"github.com/charlysan/goneo4jgql/internal/app/models"
 	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/charlysan/goneo4jgql/pkg/metrics"
 	"github.com/neo4j/neo4j-go-driver/neo4j"
 	"github.com/spf13/viper"
 )
@@ -21,12 +22,14 @@ func NewNeo4jConnection() (neo4j.Driver, error) {
 		neo4j.BasicAuth(viper.GetString("NEO4J_USER"), viper.GetString("NEO4J_PASS"), ""),
 		func(c *neo4j.Config) {
 			c.Encrypted = false
+			c.MaxConnectionPoolSize = viper.GetInt("NEO4J_MAX_POOL_SIZE")
 		})
 	if err != nil {
 		logger.Error("Cannot connect to Neo4j Server", err)
 		return nil, err
 	}
 
+	metrics.SetPoolMaxSize(viper.GetInt("NEO4J_MAX_POOL_SIZE"))
 	logger.Info("Connected to Neo4j Server", logger.LogFields{"neo4j_server_uri": target})
 
 	return driver, nil
@@ -35,6 +38,8 @@ func NewNeo4jConnection() (neo4j.Driver, error) {
 // Neo4jRepository is a Neo4j DB repository
 type Neo4jRepository struct {
 	Connection neo4j.Driver
+	// Logger is used for query and error logs, the default logger if nil
+	Logger *logger.Entry
 }
 
 // FindMovieByUUID finds a movie by its uuid
@@ -42,178 +47,134 @@ func (r *Neo4jRepository) FindMovieByUUID(ctx context.Context, uuid string) (*mo
 	query := `
 		match (m:Movie) where m.uuid = $uuid return m.uuid, m.title, m.released, m.tagline
 	`
-	session, err := r.Connection.Session(neo4j.AccessModeWrite)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer session.Close()
 
 	args := map[string]interface{}{
 		"uuid": uuid,
 	}
 
-	result, err := session.Run(query, args)
-
-	if err != nil {
-		logger.Error("Cannot find movie by uuid", logger.LogFields{"uuid": uuid}, err)
-	}
-
-	logger.Debug("CYPHER_QUERY", logger.LogFields{"query": query, "args": args})
-
 	movie := models.Movie{}
 
-	for result.Next() {
-		ParseCypherQueryResult(result.Record(), "m", &movie)
+	err := r.run(ctx, "FindMovieByUUID", query, args, func(record neo4j.Record) {
+		ParseCypherQueryResult(record, "m", &movie)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot find movie by uuid", logger.LogFields{"uuid": uuid}, err)
 	}
 
 	return &movie, err
 }
 
-// FindMovies finds movies by title and actor
-func (r *Neo4jRepository) FindMovies(ctx context.Context, title *string, actor *string) ([]*models.Movie, error) {
+// FindMovies finds movies by title and actor, ordered by title
+func (r *Neo4jRepository) FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
 	movieTitle := ""
 	actorName := ""
 
 	query := `
-		match (m:Movie) return m.uuid, m.title, m.released, m.tagline
+		match (m:Movie) return m.uuid, m.title, m.released, m.tagline order by m.title
 	`
 
 	if title != nil {
 		query = `
-			match (m:Movie) where lower(m.title) contains $movieTitle return m.uuid, m.title, m.released, m.tagline
+			match (m:Movie) where lower(m.title) contains $movieTitle return m.uuid, m.title, m.released, m.tagline order by m.title
 		`
 		movieTitle = *title
 	}
 
 	if actor != nil {
 		query = `
-			match (m:Movie)-[r:ACTED_IN]-(p:Person) where lower(p.name) contains $actor return m.uuid, m.title, m.released, m.tagline
+			match (m:Movie)-[r:ACTED_IN]-(p:Person) where lower(p.name) contains $actor return m.uuid, m.title, m.released, m.tagline order by m.title
 		`
 		actorName = *actor
 	}
 
 	if title != nil && actor != nil {
 		query = `
-			match (m:Movie)-[r:ACTED_IN]-(p:Person) where lower(m.title) contains $movieTitle and lower(p.name) contains $actor return m.uuid, m.title, m.released, m.tagline
+			match (m:Movie)-[r:ACTED_IN]-(p:Person) where lower(m.title) contains $movieTitle and lower(p.name) contains $actor return m.uuid, m.title, m.released, m.tagline order by m.title
 		`
 		movieTitle = *title
 		actorName = *actor
 	}
 
-	session, err := r.Connection.Session(neo4j.AccessModeWrite)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer session.Close()
-
 	args := map[string]interface{}{
 		"movieTitle": strings.ToLower(movieTitle),
 		"actor":      strings.ToLower(actorName),
 	}
-
-	result, err := session.Run(query, args)
-	if err != nil {
-		logger.Error("Cannot find movies", err)
-	}
-
-	logger.Debug("CYPHER_QUERY", logger.LogFields{"query": query, "args": args})
+	query += limitClause(limit, args)
 
 	var movies []*models.Movie
 
-	for result.Next() {
+	err := r.run(ctx, "FindMovies", query, args, func(record neo4j.Record) {
 		movie := models.Movie{}
-		ParseCypherQueryResult(result.Record(), "m", &movie)
+		ParseCypherQueryResult(record, "m", &movie)
 
 		movies = append(movies, &movie)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot find movies", err)
 	}
 
 	return movies, err
 }
 
-// FindMovieParticipationsByPersonUUID finds people that participated in a movie
-func (r *Neo4jRepository) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string) ([]*model.Participation, error) {
+// FindMovieParticipationsByPersonUUID finds people that participated in a movie, ordered by movie title and role
+func (r *Neo4jRepository) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error) {
 	query := `
-		match (m:Movie)-[relatedTo]-(p:Person) where p.uuid = $uuid return m.uuid, m.title, m.released, m.tagline, type(relatedTo) as role
+		match (m:Movie)-[relatedTo]-(p:Person) where p.uuid = $uuid return m.uuid, m.title, m.released, m.tagline, type(relatedTo) as role order by m.title, role
 	`
-	session, err := r.Connection.Session(neo4j.AccessModeWrite)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer session.Close()
 
 	args := map[string]interface{}{
 		"uuid": uuid,
 	}
-
-	result, err := session.Run(query, args)
-	if err != nil {
-		logger.Error("Cannot find movies", err)
-	}
-
-	logger.Debug("CYPHER_QUERY", logger.LogFields{"query": query, "args": args})
+	query += limitClause(limit, args)
 
 	var participations []*model.Participation
 
-	for result.Next() {
+	err := r.run(ctx, "FindMovieParticipationsByPersonUUID", query, args, func(record neo4j.Record) {
 		movie := models.Movie{}
-		ParseCypherQueryResult(result.Record(), "m", &movie)
+		ParseCypherQueryResult(record, "m", &movie)
 		participation := model.Participation{
 			Movie: &movie,
 		}
 		// Append Role
-		if role, ok := result.Record().Get("role"); ok {
+		if role, ok := record.Get("role"); ok {
 			participation.Role = role.(string)
 		}
 
 		participations = append(participations, &participation)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot find movies", err)
 	}
 
 	return participations, err
 }
 
-// FindPersonByMovieUUID finds people (actors, directors, writers) by movie uuid
-func (r *Neo4jRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string) ([]*models.Person, error) {
+// FindPersonByMovieUUID finds people (actors, directors, writers) by movie uuid, ordered by name
+func (r *Neo4jRepository) FindPersonByMovieUUID(ctx context.Context, role string, uuid string, limit *int) ([]*models.Person, error) {
 	query := `
-		match (p:Person)-[:%s]->(m:Movie)  where m.uuid = $uuid return p.uuid, p.name, p.born
+		match (p:Person)-[:%s]->(m:Movie)  where m.uuid = $uuid return p.uuid, p.name, p.born order by p.name
 	`
 	query = fmt.Sprintf(query, role)
 
-	session, err := r.Connection.Session(neo4j.AccessModeWrite)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer session.Close()
-
 	args := map[string]interface{}{
 		"uuid": uuid,
 		"role": role,
 	}
-
-	result, err := session.Run(query, args)
-	if err != nil {
-		logger.Error("Cannot find any person with that role", err, logger.LogFields{"role": role})
-	}
-
-	logger.Debug("CYPHER_QUERY", logger.LogFields{"query": query, "args": args})
+	query += limitClause(limit, args)
 
 	var people []*models.Person
 
-	for result.Next() {
+	err := r.run(ctx, "FindPersonByMovieUUID", query, args, func(record neo4j.Record) {
 		person := models.Person{}
-		ParseCypherQueryResult(result.Record(), "p", &person)
+		ParseCypherQueryResult(record, "p", &person)
 		// Append Role
 		person.Role = StringPtr(role)
 
 		people = append(people, &person)
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).Error("Cannot find any person with that role", err, logger.LogFields{"role": role})
 	}
 
-	return people, nil
+	return people, err
 }
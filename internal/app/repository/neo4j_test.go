@@ -0,0 +1,129 @@
+// Tests running the Neo4j driver against a bolttest server need the seabolt connector,
+// they are excluded by the noseabolt build tag where it is not installed.
+
+//go:build !noseabolt
+// +build !noseabolt
+
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/pkg/bolttest"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubRepository returns a repository connected to a Bolt stub server
+func stubRepository(t *testing.T) (*Neo4jRepository, *bolttest.Server, *logger.CaptureSink) {
+	viper.Set("NEO4J_SLOW_QUERY_THRESHOLD", "-1s")
+
+	server := bolttest.NewServer(t)
+	driver, err := neo4j.NewDriver(server.URI(), neo4j.NoAuth(), func(c *neo4j.Config) {
+		c.Encrypted = false
+		c.MaxConnectionPoolSize = 1
+	})
+	if err != nil {
+		t.Fatalf("Cannot create Neo4j driver (build with -tags noseabolt to skip driver tests): %v", err)
+	}
+	t.Cleanup(func() { driver.Close() })
+
+	capture := logger.NewCaptureSink()
+	return &Neo4jRepository{Connection: driver, Logger: logger.New(capture)}, server, capture
+}
+
+func TestNeo4jFindMovieByUUID(t *testing.T) {
+	r, server, _ := stubRepository(t)
+
+	server.Expect("match (m:Movie) where m.uuid = $uuid return m.uuid, m.title, m.released, m.tagline", map[string]interface{}{"uuid": "1"}).
+		Fields("m.uuid", "m.title", "m.released", "m.tagline").
+		Record("1", "The Matrix", 1999, "Welcome to the Real World")
+	server.Expect("match (m:Movie) where m.uuid = $uuid return m.uuid, m.title, m.released, m.tagline", map[string]interface{}{"uuid": "2"}).
+		Fields("m.uuid", "m.title", "m.released", "m.tagline")
+
+	movie, err := r.FindMovieByUUID(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Movie{UUID: "1", Title: "The Matrix", Released: 1999, Tagline: "Welcome to the Real World"}, movie)
+
+	movie, err = r.FindMovieByUUID(context.Background(), "2")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Movie{}, movie)
+}
+
+func TestNeo4jFindPersonByMovieUUID(t *testing.T) {
+	r, server, _ := stubRepository(t)
+
+	server.Expect("match (p:Person)-[:DIRECTED]->(m:Movie) where m.uuid = $uuid return p.uuid, p.name, p.born order by p.name", map[string]interface{}{"uuid": "1", "role": "DIRECTED"}).
+		Fields("p.uuid", "p.name", "p.born").
+		Record("2", "Lana Wachowski", 1965).
+		Record("3", "Lilly Wachowski", nil)
+
+	people, err := r.FindPersonByMovieUUID(context.Background(), "DIRECTED", "1", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []*models.Person{
+		{UUID: "2", Name: "Lana Wachowski", Born: 1965, Role: StringPtr("DIRECTED")},
+		{UUID: "3", Name: "Lilly Wachowski", Role: StringPtr("DIRECTED")},
+	}, people)
+}
+
+func TestNeo4jLimit(t *testing.T) {
+	r, server, _ := stubRepository(t)
+
+	server.Expect("match (m:Movie) return m.uuid, m.title, m.released, m.tagline order by m.title limit $limit", map[string]interface{}{"movieTitle": "", "actor": "", "limit": 1}).
+		Fields("m.uuid", "m.title", "m.released", "m.tagline").
+		Record("1", "A Few Good Men", 1992, nil)
+	server.Expect("match (p:Person)-[:ACTED_IN]->(m:Movie) where m.uuid = $uuid return p.uuid, p.name, p.born order by p.name limit $limit", map[string]interface{}{"uuid": "1", "role": "ACTED_IN", "limit": 0}).
+		Fields("p.uuid", "p.name", "p.born")
+
+	movies, err := r.FindMovies(context.Background(), nil, nil, IntPtr(1))
+	assert.Nil(t, err)
+	assert.Len(t, movies, 1)
+
+	people, err := r.FindPersonByMovieUUID(context.Background(), "ACTED_IN", "1", IntPtr(0))
+	assert.Nil(t, err)
+	assert.Empty(t, people)
+}
+
+func TestNeo4jQueryErrors(t *testing.T) {
+	r, server, capture := stubRepository(t)
+
+	server.Expect("match (m:Movie) return m.uuid, m.title, m.released, m.tagline order by m.title", nil).
+		Fail("Neo.TransientError.General.DatabaseUnavailable", "Database unavailable")
+	server.Expect("match (m:Movie) return m.uuid, m.title, m.released, m.tagline order by m.title", nil).
+		Disconnect()
+
+	for i := 0; i < 2; i++ {
+		movies, err := r.FindMovies(context.Background(), nil, nil, nil)
+		assert.NotNil(t, err)
+		assert.Empty(t, movies)
+	}
+
+	assert.Len(t, capture.Find("Cannot find movies"), 2)
+}
+
+func TestNeo4jTransientErrorsNotRetried(t *testing.T) {
+	r, server, _ := stubRepository(t)
+
+	query := "match (m:Movie) where m.uuid = $uuid return m.uuid, m.title, m.released, m.tagline"
+	server.Expect(query, map[string]interface{}{"uuid": "1"}).
+		Fail("Neo.TransientError.General.DatabaseUnavailable", "Database unavailable")
+	server.Expect(query, map[string]interface{}{"uuid": "1"}).
+		Fields("m.uuid", "m.title", "m.released", "m.tagline").
+		Record("1", "The Matrix", 1999, "Welcome to the Real World")
+
+	// Queries run in auto-commit transactions, which the driver does not retry: the
+	// error is returned, and the query is sent once
+	_, err := r.FindMovieByUUID(context.Background(), "1")
+	assert.NotNil(t, err)
+	assert.Len(t, server.Queries(), 1)
+
+	// The connection can be used again, and the next call gets the next answer
+	movie, err := r.FindMovieByUUID(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "The Matrix", movie.Title)
+	assert.Len(t, server.Queries(), 2)
+}
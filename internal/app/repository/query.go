@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/charlysan/goneo4jgql/pkg/metrics"
+	"github.com/charlysan/goneo4jgql/pkg/tracing"
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/spf13/viper"
+)
+
+// QueryPlanMode type used for specifying how slow queries plans are obtained
+type QueryPlanMode string
+
+const (
+	// QueryPlanNone does not attach any plan to slow queries
+	QueryPlanNone QueryPlanMode = "none"
+	// QueryPlanExplain attaches the plan returned by EXPLAIN (the query is not executed again)
+	QueryPlanExplain QueryPlanMode = "explain"
+	// QueryPlanProfile attaches the plan returned by PROFILE, including db hits (the query is executed again)
+	QueryPlanProfile QueryPlanMode = "profile"
+)
+
+// maxLoggedArgLength is the maximum length, in bytes, of string parameters written to slow query logs
+const maxLoggedArgLength = 64
+
+// sensitiveArgs matches the names of parameters that are never written to slow query
+// logs, whatever the logger redaction settings (e.g. API key hashes)
+var sensitiveArgs = regexp.MustCompile(`(?i)(hash|password|passwd|secret|token)`)
+
+// session opens a new session, keeping track of how many sessions are in use
+func (r *Neo4jRepository) session() (neo4j.Session, error) {
+	start := time.Now()
+	session, err := r.Connection.Session(neo4j.AccessModeWrite)
+	metrics.ObserveSessionAcquisition(start, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trackedSession{Session: session}, nil
+}
+
+// trackedSession reports to metrics when it gets closed
+type trackedSession struct {
+	neo4j.Session
+	closed bool
+}
+
+// Close closes the underlying session
+func (s *trackedSession) Close() error {
+	if !s.closed {
+		s.closed = true
+		metrics.SessionClosed()
+	}
+
+	return s.Session.Close()
+}
+
+// run executes a cypher query on a new session and calls handle for every record returned.
+// Every query is measured, traced and logged at debug level, and queries taking longer than
+// NEO4J_SLOW_QUERY_THRESHOLD, failed ones included, are logged (a zero threshold logs every
+// query, a negative one none). Queries run in auto-commit transactions, which the driver
+// does not retry: transient errors are returned to the caller.
+func (r *Neo4jRepository) run(ctx context.Context, method string, query string, args map[string]interface{}, handle func(neo4j.Record)) error {
+	session, err := r.session()
+	if err != nil {
+		return err
+	}
+
+	defer session.Close()
+
+	ctx, span := tracing.StartQuerySpan(ctx, method, query)
+	start := time.Now()
+	rows := 0
+
+	result, err := session.Run(query, args)
+	if err == nil {
+		for result.Next() {
+			handle(result.Record())
+			rows++
+		}
+		err = result.Err()
+	}
+
+	duration := time.Since(start)
+	metrics.ObserveCypherQuery(method, start, err)
+	tracing.EndQuerySpan(ctx, span, rows, err)
+
+	r.Logger.WithContext(ctx).Debug("CYPHER_QUERY", logger.LogFields{
+		"method":      method,
+		"rows":        rows,
+		"duration_ms": float64(duration.Microseconds()) / 1000,
+	})
+
+	threshold := viper.GetDuration("NEO4J_SLOW_QUERY_THRESHOLD")
+	if threshold >= 0 && duration >= threshold {
+		r.logSlowQuery(ctx, session, method, query, args, rows, duration, err)
+	}
+
+	return err
+}
+
+// logSlowQuery logs a query that took too long, with the error it failed with if any.
+// The plan summary of successful queries is attached if NEO4J_SLOW_QUERY_PLAN is set.
+// Sensitive args are redacted, and the others are redacted by the logger like any log field.
+func (r *Neo4jRepository) logSlowQuery(ctx context.Context, session neo4j.Session, method string, query string, args map[string]interface{}, rows int, duration time.Duration, err error) {
+	fields := logger.LogFields{
+		"method":      method,
+		"query":       tracing.SanitizeCypher(query),
+		"args":        loggedArgs(args),
+		"rows":        rows,
+		"duration_ms": float64(duration.Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		r.Logger.WithContext(ctx).Warning("SLOW_CYPHER_QUERY", err, fields)
+		return
+	}
+
+	mode := QueryPlanMode(strings.ToLower(viper.GetString("NEO4J_SLOW_QUERY_PLAN")))
+	if mode == QueryPlanExplain || mode == QueryPlanProfile {
+		plan, err := queryPlan(session, mode, query, args)
+		if err != nil {
+			r.Logger.WithContext(ctx).Warning("Cannot obtain query plan", err, logger.LogFields{"method": method})
+		} else {
+			fields["plan"] = plan
+		}
+	}
+
+	r.Logger.WithContext(ctx).Warning("SLOW_CYPHER_QUERY", fields)
+}
+
+// planSummary is a condensed version of a query plan
+type planSummary struct {
+	Operators []string `json:"operators"`
+	DbHits    int64    `json:"db_hits,omitempty"`
+	Indexes   []string `json:"indexes"`
+	UsesIndex bool     `json:"uses_index"`
+}
+
+// queryPlan runs the query again prefixed with EXPLAIN or PROFILE and summarizes the returned plan
+func queryPlan(session neo4j.Session, mode QueryPlanMode, query string, args map[string]interface{}) (*planSummary, error) {
+	result, err := session.Run(fmt.Sprintf("%s %s", strings.ToUpper(string(mode)), query), args)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := result.Consume()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &planSummary{Operators: []string{}, Indexes: []string{}}
+
+	if mode == QueryPlanProfile && summary.Profile() != nil {
+		summarizeProfiledPlan(summary.Profile(), plan)
+	} else if summary.Plan() != nil {
+		summarizePlan(summary.Plan(), plan)
+	}
+
+	plan.UsesIndex = len(plan.Indexes) > 0
+
+	return plan, nil
+}
+
+func summarizePlan(p neo4j.Plan, summary *planSummary) {
+	addOperator(summary, p.Operator(), p.Arguments())
+	for _, child := range p.Children() {
+		summarizePlan(child, summary)
+	}
+}
+
+func summarizeProfiledPlan(p neo4j.ProfiledPlan, summary *planSummary) {
+	addOperator(summary, p.Operator(), p.Arguments())
+	summary.DbHits += p.DbHits()
+	for _, child := range p.Children() {
+		summarizeProfiledPlan(child, summary)
+	}
+}
+
+func addOperator(summary *planSummary, operator string, arguments map[string]interface{}) {
+	summary.Operators = append(summary.Operators, operator)
+
+	if !strings.Contains(strings.ToLower(operator), "index") {
+		return
+	}
+
+	// Index operators describe the index used (e.g. ":Movie(uuid)") in their arguments
+	index := operator
+	for _, key := range []string{"Index", "index", "Details"} {
+		if v, ok := arguments[key]; ok {
+			index = fmt.Sprintf("%s %v", operator, v)
+			break
+		}
+	}
+	summary.Indexes = append(summary.Indexes, index)
+}
+
+// loggedArgs returns a copy of args with sensitive parameters redacted and long strings
+// truncated (on a rune boundary), so they don't bloat logs
+func loggedArgs(args map[string]interface{}) map[string]interface{} {
+	logged := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if sensitiveArgs.MatchString(k) {
+			v = logger.Redacted
+		} else if s, ok := v.(string); ok && len(s) > maxLoggedArgLength {
+			end := maxLoggedArgLength
+			for end > 0 && !utf8.RuneStart(s[end]) {
+				end--
+			}
+			v = s[:end] + "..."
+		}
+		logged[k] = v
+	}
+
+	return logged
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSlowQuery(t *testing.T) {
+	capture := logger.NewCaptureSink()
+	r := &Neo4jRepository{Logger: logger.New(capture)}
+	args := map[string]interface{}{
+		"title":    strings.Repeat("a", maxLoggedArgLength+10),
+		"password": "hunter2",
+		"contact":  "keanu@example.com",
+		"limit":    10,
+	}
+
+	// Failed queries are logged with their error, without a plan
+	r.logSlowQuery(context.Background(), nil, "FindMovies", "MATCH (m:Movie) RETURN m", args, 0, 2*time.Second, errors.New("transaction timed out"))
+
+	logged := capture.Find("SLOW_CYPHER_QUERY")
+	if assert.Len(t, logged, 1) {
+		assert.Equal(t, logger.LevelWarning, logged[0].Level)
+		assert.Equal(t, "transaction timed out", logged[0].Fields["error_msg"])
+		assert.Nil(t, logged[0].Fields["plan"])
+
+		// Parameters are truncated and redacted
+		args := logged[0].Fields["args"].(map[string]interface{})
+		assert.Equal(t, strings.Repeat("a", maxLoggedArgLength)+"...", args["title"])
+		assert.Equal(t, logger.Redacted, args["password"])
+		assert.Equal(t, logger.Redacted, args["contact"])
+		assert.Equal(t, 10, args["limit"])
+	}
+}
+
+func TestLoggedArgs(t *testing.T) {
+	// Sensitive parameters are redacted even when the logger does not redact anything
+	logged := loggedArgs(map[string]interface{}{"id": "0123456789abcdef", "hash": "c0ffee", "resetToken": "t0k3n"})
+	assert.Equal(t, map[string]interface{}{"id": "0123456789abcdef", "hash": logger.Redacted, "resetToken": logger.Redacted}, logged)
+
+	// Strings are truncated on rune boundaries
+	title := strings.Repeat("a", maxLoggedArgLength-1) + "é"
+	logged = loggedArgs(map[string]interface{}{"title": title})
+	assert.Equal(t, strings.Repeat("a", maxLoggedArgLength-1)+"...", logged["title"])
+	assert.True(t, utf8.ValidString(logged["title"].(string)))
+
+	short := strings.Repeat("é", maxLoggedArgLength/2)
+	assert.Equal(t, short, loggedArgs(map[string]interface{}{"title": short})["title"])
+}
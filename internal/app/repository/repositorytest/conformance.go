@@ -0,0 +1,267 @@
+// Package repositorytest provides a conformance test suite for repository.Repository
+// implementations. Every implementation must pass it with the movies dataset
+// (neo4j/import/movies.cypher) loaded, so they all behave identically.
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+	"github.com/charlysan/goneo4jgql/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// MoviesCount is the number of movies in the movies dataset
+const MoviesCount = 38
+
+// Run runs the conformance suite against r
+func Run(t *testing.T, r repository.Repository) {
+	s := &suite{r: r, ctx: context.Background()}
+
+	t.Run("FindMovieByUUID", s.findMovieByUUID)
+	t.Run("FindMovies", s.findMovies)
+	t.Run("FindMoviesByTitle", s.findMoviesByTitle)
+	t.Run("FindMoviesByActor", s.findMoviesByActor)
+	t.Run("FindMovieParticipationsByPersonUUID", s.findMovieParticipations)
+	t.Run("FindPersonByMovieUUID", s.findPersonByMovieUUID)
+	t.Run("Limit", s.limit)
+}
+
+type suite struct {
+	r   repository.Repository
+	ctx context.Context
+}
+
+// movie finds a movie by its exact title, as uuids differ between backends
+func (s *suite) movie(t *testing.T, title string) *models.Movie {
+	movies, err := s.r.FindMovies(s.ctx, &title, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range movies {
+		if m.Title == title {
+			return m
+		}
+	}
+
+	t.Fatalf("Movie not found: %s", title)
+	return nil
+}
+
+// person finds a person by its exact name among the people related to a movie
+func (s *suite) person(t *testing.T, role string, movie string, name string) *models.Person {
+	people, err := s.r.FindPersonByMovieUUID(s.ctx, role, s.movie(t, movie).UUID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range people {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	t.Fatalf("Person not found: %s", name)
+	return nil
+}
+
+func titles(movies []*models.Movie) []string {
+	res := []string{}
+	for _, m := range movies {
+		res = append(res, m.Title)
+	}
+
+	return res
+}
+
+func names(people []*models.Person) []string {
+	res := []string{}
+	for _, p := range people {
+		res = append(res, p.Name)
+	}
+
+	return res
+}
+
+func (s *suite) findMovieByUUID(t *testing.T) {
+	expected := s.movie(t, "The Matrix")
+
+	movie, err := s.r.FindMovieByUUID(s.ctx, expected.UUID)
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Movie{
+		UUID:     expected.UUID,
+		Title:    "The Matrix",
+		Released: 1999,
+		Tagline:  "Welcome to the Real World",
+	}, movie)
+
+	// Not found returns an empty movie and no error
+	movie, err = s.r.FindMovieByUUID(s.ctx, "00000000-0000-0000-0000-000000000000")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Movie{}, movie)
+}
+
+func (s *suite) findMovies(t *testing.T) {
+	movies, err := s.r.FindMovies(s.ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, movies, MoviesCount)
+
+	// Ordered by title
+	for i := 1; i < len(movies); i++ {
+		assert.True(t, movies[i-1].Title <= movies[i].Title, "%q before %q", movies[i-1].Title, movies[i].Title)
+	}
+
+	// Missing properties are returned as zero values
+	for _, m := range movies {
+		assert.NotEmpty(t, m.UUID)
+		assert.NotEmpty(t, m.Title)
+		if m.Title == "Something's Gotta Give" {
+			assert.Equal(t, "", m.Tagline)
+		}
+	}
+}
+
+func (s *suite) findMoviesByTitle(t *testing.T) {
+	for _, title := range []string{"matrix", "MATRIX", "MaTrIx"} {
+		movies, err := s.r.FindMovies(s.ctx, &title, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"The Matrix", "The Matrix Reloaded", "The Matrix Revolutions"}, titles(movies), title)
+	}
+
+	title := "no such movie"
+	movies, err := s.r.FindMovies(s.ctx, &title, nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, movies)
+}
+
+func (s *suite) findMoviesByActor(t *testing.T) {
+	actor := "KEANU reeves"
+	movies, err := s.r.FindMovies(s.ctx, nil, &actor, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"Johnny Mnemonic",
+		"Something's Gotta Give",
+		"The Devil's Advocate",
+		"The Matrix",
+		"The Matrix Reloaded",
+		"The Matrix Revolutions",
+		"The Replacements",
+	}, titles(movies))
+
+	title := "matrix"
+	actor = "carrie"
+	movies, err = s.r.FindMovies(s.ctx, &title, &actor, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"The Matrix", "The Matrix Reloaded", "The Matrix Revolutions"}, titles(movies))
+
+	// A movie is returned once for every matching actor
+	title = "The Matrix Revolutions"
+	actor = ""
+	movies, err = s.r.FindMovies(s.ctx, &title, &actor, nil)
+	assert.Nil(t, err)
+	assert.Len(t, movies, 4)
+
+	// Directors are not actors
+	actor = "wachowski"
+	movies, err = s.r.FindMovies(s.ctx, nil, &actor, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, movies)
+}
+
+func (s *suite) findMovieParticipations(t *testing.T) {
+	tom := s.person(t, "ACTED_IN", "Cloud Atlas", "Tom Hanks")
+
+	participations, err := s.r.FindMovieParticipationsByPersonUUID(s.ctx, tom.UUID, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, participations, 13) {
+		// Ordered by movie title and role, including every kind of relationship
+		got := []string{}
+		for _, p := range participations {
+			got = append(got, p.Movie.Title+"/"+p.Role)
+		}
+		assert.Equal(t, []string{"Sleepless in Seattle/ACTED_IN", "That Thing You Do/ACTED_IN", "That Thing You Do/DIRECTED"}, got[6:9])
+		assert.Equal(t, s.movie(t, "That Thing You Do"), participations[8].Movie)
+	}
+
+	jessica := s.person(t, "REVIEWED", "Cloud Atlas", "Jessica Thompson")
+	participations, err = s.r.FindMovieParticipationsByPersonUUID(s.ctx, jessica.UUID, nil)
+	assert.Nil(t, err)
+	assert.Len(t, participations, 6)
+	for _, p := range participations {
+		assert.Equal(t, "REVIEWED", p.Role)
+	}
+
+	participations, err = s.r.FindMovieParticipationsByPersonUUID(s.ctx, "00000000-0000-0000-0000-000000000000", nil)
+	assert.Nil(t, err)
+	assert.Empty(t, participations)
+}
+
+func (s *suite) findPersonByMovieUUID(t *testing.T) {
+	matrix := s.movie(t, "The Matrix").UUID
+
+	for role, expected := range map[string][]string{
+		"ACTED_IN": {"Carrie-Anne Moss", "Emil Eifrem", "Hugo Weaving", "Keanu Reeves", "Laurence Fishburne"},
+		"DIRECTED": {"Lana Wachowski", "Lilly Wachowski"},
+		"PRODUCED": {"Joel Silver"},
+	} {
+		people, err := s.r.FindPersonByMovieUUID(s.ctx, role, matrix, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, names(people), role)
+
+		for _, p := range people {
+			assert.NotEmpty(t, p.UUID)
+			if assert.NotNil(t, p.Role) {
+				assert.Equal(t, role, *p.Role)
+			}
+		}
+	}
+
+	keanu := s.person(t, "ACTED_IN", "The Matrix", "Keanu Reeves")
+	assert.Equal(t, int64(1964), keanu.Born)
+
+	// Missing properties are returned as zero values
+	jessica := s.person(t, "REVIEWED", "Cloud Atlas", "Jessica Thompson")
+	assert.Equal(t, int64(0), jessica.Born)
+
+	for _, role := range []string{"WROTE", "FOLLOWS"} {
+		people, err := s.r.FindPersonByMovieUUID(s.ctx, role, matrix, nil)
+		assert.Nil(t, err)
+		assert.Empty(t, people, role)
+	}
+
+	people, err := s.r.FindPersonByMovieUUID(s.ctx, "ACTED_IN", "00000000-0000-0000-0000-000000000000", nil)
+	assert.Nil(t, err)
+	assert.Empty(t, people)
+}
+
+func (s *suite) limit(t *testing.T) {
+	zero, two := 0, 2
+
+	movies, err := s.r.FindMovies(s.ctx, nil, nil, &two)
+	assert.Nil(t, err)
+	all, _ := s.r.FindMovies(s.ctx, nil, nil, nil)
+	assert.Equal(t, titles(all[:2]), titles(movies))
+
+	title := "matrix"
+	movies, err = s.r.FindMovies(s.ctx, &title, nil, &zero)
+	assert.Nil(t, err)
+	assert.Empty(t, movies)
+
+	// Limits above the number of results return them all
+	many := MoviesCount + 1
+	movies, err = s.r.FindMovies(s.ctx, nil, nil, &many)
+	assert.Nil(t, err)
+	assert.Len(t, movies, MoviesCount)
+
+	matrix := s.movie(t, "The Matrix").UUID
+	people, err := s.r.FindPersonByMovieUUID(s.ctx, "ACTED_IN", matrix, &two)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Carrie-Anne Moss", "Emil Eifrem"}, names(people))
+
+	tom := s.person(t, "ACTED_IN", "Cloud Atlas", "Tom Hanks")
+	participations, err := s.r.FindMovieParticipationsByPersonUUID(s.ctx, tom.UUID, &two)
+	assert.Nil(t, err)
+	assert.Len(t, participations, 2)
+}
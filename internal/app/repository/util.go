@@ -61,6 +61,26 @@ func IntPtr(i int) *int {
 	return &i
 }
 
+// limitClause returns the cypher clause limiting results to limit, set as the
+// $limit parameter in args, or an empty clause when limit is nil
+func limitClause(limit *int, args map[string]interface{}) string {
+	if limit == nil {
+		return ""
+	}
+
+	args["limit"] = *limit
+	return " limit $limit"
+}
+
+// limitLen returns the length a list of n results is truncated to by limit
+func limitLen(n int, limit *int) int {
+	if limit != nil && *limit < n {
+		return *limit
+	}
+
+	return n
+}
+
 // PtrOrPtrEmptyString returns pointer to an Int
 func PtrOrPtrEmptyString(ptr *string) *string {
 	if ptr == nil {
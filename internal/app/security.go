@@ -0,0 +1,111 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+	"github.com/spf13/viper"
+)
+
+// corsOptions returns the CORS options set by the CORS_* config keys, or nil when
+// CORS_ALLOWED_ORIGINS is empty and requests from other origins are not allowed
+func corsOptions() (*cors.Options, error) {
+	origins := splitList(viper.GetString("CORS_ALLOWED_ORIGINS"))
+	if len(origins) == 0 {
+		return nil, nil
+	}
+
+	o := &cors.Options{
+		AllowOriginFunc:  func(origin string) bool { return originAllowed(origins, origin) },
+		AllowedMethods:   splitList(viper.GetString("CORS_ALLOWED_METHODS")),
+		AllowedHeaders:   splitList(viper.GetString("CORS_ALLOWED_HEADERS")),
+		ExposedHeaders:   splitList(viper.GetString("CORS_EXPOSED_HEADERS")),
+		AllowCredentials: viper.GetBool("CORS_ALLOW_CREDENTIALS"),
+		MaxAge:           int(viper.GetDuration("CORS_MAX_AGE").Seconds()),
+	}
+
+	// Allowed origins are echoed back, which would let any site send credentials
+	if o.AllowCredentials && originAllowed(origins, "*") {
+		return nil, fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be set when any origin is allowed")
+	}
+
+	return o, nil
+}
+
+// originAllowed tells whether origin is one of allowed, which can hold "*" (any origin)
+// and origins with a "*" wildcard (e.g. "https://*.example.com")
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*" || a == origin {
+			return true
+		}
+
+		if i := strings.IndexByte(a, '*'); i >= 0 {
+			prefix, suffix := a[:i], a[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkOrigin returns the websocket origin check, letting connections through when they
+// come from the same origin, as gorilla/websocket does by default, or from an origin
+// allowed by c (if any). Browsers do not apply CORS to websockets, so this is the only
+// check they get.
+func checkOrigin(c *cors.Options) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+
+		return c != nil && c.AllowOriginFunc(origin)
+	}
+}
+
+// securityHeaders sets response headers hardening how browsers handle responses.
+// Strict-Transport-Security is only set when hstsMaxAge is positive, as TLS is
+// usually terminated by a proxy in front of the API.
+func securityHeaders(hstsMaxAge time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Content-Security-Policy", "frame-ancestors 'none'")
+			h.Set("Referrer-Policy", "no-referrer")
+			if hstsMaxAge > 0 {
+				h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(hstsMaxAge.Seconds())))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// splitList splits a comma separated config value, ignoring empty items
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
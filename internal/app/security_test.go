@@ -0,0 +1,160 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charlysan/goneo4jgql/internal/app/repository"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://movies.example.com", "https://*.example.org"}
+
+	assert.True(t, originAllowed(allowed, "https://movies.example.com"))
+	assert.True(t, originAllowed(allowed, "HTTPS://Movies.Example.com"))
+	assert.True(t, originAllowed(allowed, "https://admin.example.org"))
+	assert.False(t, originAllowed(allowed, "https://example.com"))
+	assert.False(t, originAllowed(allowed, "https://example.org"))
+	assert.False(t, originAllowed(allowed, "https://evil.com/.example.org.com"))
+	assert.True(t, originAllowed([]string{"*"}, "https://evil.com"))
+	assert.False(t, originAllowed(nil, "https://movies.example.com"))
+}
+
+func TestCORSOptions(t *testing.T) {
+	viper.Set("CORS_ALLOWED_ORIGINS", "")
+	t.Cleanup(func() {
+		viper.Set("CORS_ALLOWED_ORIGINS", "")
+		viper.Set("CORS_ALLOW_CREDENTIALS", false)
+	})
+
+	o, err := corsOptions()
+	assert.Nil(t, err)
+	assert.Nil(t, o)
+
+	viper.Set("CORS_ALLOWED_ORIGINS", "https://movies.example.com, https://*.example.org")
+	viper.Set("CORS_ALLOW_CREDENTIALS", true)
+	o, err = corsOptions()
+	assert.Nil(t, err)
+	if assert.NotNil(t, o) {
+		assert.True(t, o.AllowOriginFunc("https://admin.example.org"))
+		assert.True(t, o.AllowCredentials)
+	}
+
+	viper.Set("CORS_ALLOWED_ORIGINS", "*")
+	_, err = corsOptions()
+	assert.NotNil(t, err)
+}
+
+func newCORSTestApp(t *testing.T) *App {
+	viper.Set("CORS_ALLOWED_ORIGINS", "https://movies.example.com")
+	viper.Set("CORS_ALLOWED_METHODS", "GET,POST,OPTIONS")
+	viper.Set("CORS_ALLOWED_HEADERS", "Authorization,Content-Type")
+	viper.Set("CORS_EXPOSED_HEADERS", "ETag")
+	viper.Set("CORS_MAX_AGE", "10m")
+	viper.Set("SECURITY_HSTS_MAX_AGE", "24h")
+	t.Cleanup(func() {
+		for _, key := range []string{"CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_METHODS", "CORS_ALLOWED_HEADERS", "CORS_EXPOSED_HEADERS", "CORS_MAX_AGE", "SECURITY_HSTS_MAX_AGE"} {
+			viper.Set(key, "")
+		}
+	})
+
+	r, err := repository.NewInMemoryRepository(moviesFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestApp(t, r)
+	a.CORS, err = corsOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.InitRoutes()
+
+	return a
+}
+
+func TestCORS(t *testing.T) {
+	a := newCORSTestApp(t)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/movies", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		rec := httptest.NewRecorder()
+		a.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://movies.example.com")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://movies.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = preflight("https://evil.com")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	req := httptest.NewRequest(http.MethodGet, "/movies?query={movies(title:\"matrix\"){title}}", nil)
+	req.Header.Set("Origin", "https://movies.example.com")
+	rec = httptest.NewRecorder()
+	a.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://movies.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Etag", rec.Header().Get("Access-Control-Expose-Headers"))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	a := newCORSTestApp(t)
+
+	for _, path := range []string{"/movies", "/playground", "/metrics"} {
+		rec := httptest.NewRecorder()
+		a.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"), path)
+		assert.Equal(t, "frame-ancestors 'none'", rec.Header().Get("Content-Security-Policy"), path)
+		assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"), path)
+		assert.Equal(t, "max-age=86400; includeSubDomains", rec.Header().Get("Strict-Transport-Security"), path)
+	}
+}
+
+func TestWebsocketOrigin(t *testing.T) {
+	a := newCORSTestApp(t)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "", allowed: true},
+		{origin: "http://api.example.com", allowed: true},
+		{origin: "https://movies.example.com", allowed: true},
+		{origin: "https://evil.com", allowed: false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/movies", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		assert.Equal(t, tt.allowed, checkOrigin(a.CORS)(req), tt.origin)
+		assert.Equal(t, tt.origin == "" || tt.origin == "http://api.example.com", checkOrigin(nil)(req), tt.origin)
+	}
+
+	// The check is used by the websocket transport
+	req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+	req.Header.Set("Connection", "upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-Websocket-Version", "13")
+	req.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-Websocket-Protocol", "graphql-ws")
+	req.Header.Set("Origin", "https://evil.com")
+	rec := httptest.NewRecorder()
+	a.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+
+	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
+	"github.com/charlysan/goneo4jgql/internal/app/models"
+)
+
+// MovieService definition for services used by resolvers
+type MovieService interface {
+	// Movie
+	FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error)
+	FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error)
+	FindDirectorsByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error)
+	FindWritersByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error)
+	FindCastByMovieUUID(ctx context.Context, uuid string, limit *int) ([]*models.Person, error)
+	FindReviewersByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error)
+	// Person
+	FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error)
+}
+
+var _ MovieService = &Service{}
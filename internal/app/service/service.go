@@ -6,46 +6,87 @@ import (
 	"github.com/charlysan/goneo4jgql/internal/app/graph/model"
 	"github.com/charlysan/goneo4jgql/internal/app/models"
 	"github.com/charlysan/goneo4jgql/internal/app/repository"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/charlysan/goneo4jgql/pkg/tracing"
 )
 
 // Service exposes application bussiness logic
 type Service struct {
 	repository repository.Repository
+	logger     *logger.Entry
 }
 
-// NewService creates a new service
-func NewService(r repository.Repository) Service {
+// NewService creates a new service logging to l (the default logger if nil)
+func NewService(r repository.Repository, l *logger.Entry) Service {
 	return Service{
 		repository: r,
+		logger:     l,
 	}
 }
 
 // FindMovieByUUID finds a movie by its uuid
 func (s *Service) FindMovieByUUID(ctx context.Context, uuid string) (*models.Movie, error) {
-	return s.repository.FindMovieByUUID(ctx, uuid)
+	ctx, span := tracing.StartSpan(ctx, "service.FindMovieByUUID")
+	res, err := s.repository.FindMovieByUUID(ctx, uuid)
+	tracing.EndSpan(ctx, span, err)
+
+	if err == nil && (res == nil || res.UUID == "") {
+		s.logger.WithContext(ctx).Debug("Movie not found", logger.LogFields{"uuid": uuid})
+	}
+
+	return res, err
 }
 
-// FindMovies finds movies by title and actor
-func (s *Service) FindMovies(ctx context.Context, title *string, actor *string) ([]*models.Movie, error) {
-	return s.repository.FindMovies(ctx, title, actor)
+// FindMovies finds up to limit movies (all if nil) by title and actor
+func (s *Service) FindMovies(ctx context.Context, title *string, actor *string, limit *int) ([]*models.Movie, error) {
+	ctx, span := tracing.StartSpan(ctx, "service.FindMovies")
+	res, err := s.repository.FindMovies(ctx, title, actor, limit)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
 }
 
 // FindDirectorsByMovieUUID finds directors for a movie by movie uuid
 func (s *Service) FindDirectorsByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error) {
-	return s.repository.FindPersonByMovieUUID(ctx, "DIRECTED", uuid)
+	ctx, span := tracing.StartSpan(ctx, "service.FindDirectorsByMovieUUID")
+	res, err := s.repository.FindPersonByMovieUUID(ctx, "DIRECTED", uuid, nil)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
 }
 
 // FindWritersByMovieUUID finds writers for a movie by movie uuid
 func (s *Service) FindWritersByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error) {
-	return s.repository.FindPersonByMovieUUID(ctx, "WROTE", uuid)
+	ctx, span := tracing.StartSpan(ctx, "service.FindWritersByMovieUUID")
+	res, err := s.repository.FindPersonByMovieUUID(ctx, "WROTE", uuid, nil)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
 }
 
-// FindCastByMovieUUID finds movie cast by movie uuid
-func (s *Service) FindCastByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error) {
-	return s.repository.FindPersonByMovieUUID(ctx, "ACTED_IN", uuid)
+// FindCastByMovieUUID finds up to limit cast members (all if nil) by movie uuid
+func (s *Service) FindCastByMovieUUID(ctx context.Context, uuid string, limit *int) ([]*models.Person, error) {
+	ctx, span := tracing.StartSpan(ctx, "service.FindCastByMovieUUID")
+	res, err := s.repository.FindPersonByMovieUUID(ctx, "ACTED_IN", uuid, limit)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
 }
 
-// FindMovieParticipationsByPersonUUID finds people that participated in a movie
-func (s *Service) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string) ([]*model.Participation, error) {
-	return s.repository.FindMovieParticipationsByPersonUUID(ctx, uuid)
+// FindReviewersByMovieUUID finds movie reviewers by movie uuid
+func (s *Service) FindReviewersByMovieUUID(ctx context.Context, uuid string) ([]*models.Person, error) {
+	ctx, span := tracing.StartSpan(ctx, "service.FindReviewersByMovieUUID")
+	res, err := s.repository.FindPersonByMovieUUID(ctx, "REVIEWED", uuid, nil)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
+}
+
+// FindMovieParticipationsByPersonUUID finds up to limit movie participations (all if nil) of a person
+func (s *Service) FindMovieParticipationsByPersonUUID(ctx context.Context, uuid string, limit *int) ([]*model.Participation, error) {
+	ctx, span := tracing.StartSpan(ctx, "service.FindMovieParticipationsByPersonUUID")
+	res, err := s.repository.FindMovieParticipationsByPersonUUID(ctx, uuid, limit)
+	tracing.EndSpan(ctx, span, err)
+
+	return res, err
 }
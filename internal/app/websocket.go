@@ -0,0 +1,157 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// websocketTracker keeps track of upgraded (websocket) connections so they can be
+// closed and drained on shutdown, as http.Server.Shutdown neither closes nor waits
+// for hijacked connections
+type websocketTracker struct {
+	mu     sync.Mutex
+	conns  map[*websocketConn]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+// websocketConn is a tracked connection: the context of its request and, once
+// upgraded, the hijacked connection
+type websocketConn struct {
+	cancel context.CancelFunc
+	conn   net.Conn
+}
+
+func newWebsocketTracker() *websocketTracker {
+	return &websocketTracker{conns: map[*websocketConn]struct{}{}}
+}
+
+// Middleware tracks every request asking for a protocol upgrade until its handler returns.
+// Upgrades are refused once the tracker is closed.
+func (t *websocketTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		c := &websocketConn{cancel: cancel}
+		if !t.add(c) {
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer t.remove(c)
+
+		next.ServeHTTP(&hijackRecorder{ResponseWriter: w, tracker: t, conn: c}, r.WithContext(ctx))
+	})
+}
+
+// Active returns the number of websocket connections currently open
+func (t *websocketTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.conns)
+}
+
+// Close cancels the context of every tracked connection, ending their subscriptions,
+// and closes the upgraded ones so their handlers return. It is meant to be called when
+// shutdown starts, clients being expected to reconnect to another instance.
+func (t *websocketTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for c := range t.conns {
+		c.cancel()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// Wait blocks until every tracked connection has been closed or ctx is done
+func (t *websocketTracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.conns) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	done := t.done
+	t.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *websocketTracker) add(c *websocketConn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.conns[c] = struct{}{}
+	return true
+}
+
+func (t *websocketTracker) remove(c *websocketConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.conns, c)
+	if len(t.conns) == 0 && t.done != nil {
+		close(t.done)
+		t.done = nil
+	}
+}
+
+// hijacked records the connection hijacked for c, closing it right away if the tracker
+// was closed in the meantime
+func (t *websocketTracker) hijacked(c *websocketConn, conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c.conn = conn
+	if t.closed {
+		conn.Close()
+	}
+}
+
+// hijackRecorder hands the connection hijacked by the websocket upgrader to the tracker
+type hijackRecorder struct {
+	http.ResponseWriter
+	tracker *websocketTracker
+	conn    *websocketConn
+}
+
+// Hijack hijacks the underlying connection, which must support it
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	h.tracker.hijacked(h.conn, conn)
+
+	return conn, rw, nil
+}
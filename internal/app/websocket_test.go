@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebsocketShutdown(t *testing.T) {
+	// Subscriptions are served until the connection is closed or their context is done
+	tracker := newWebsocketTracker()
+	handler := tracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		go func() {
+			<-r.Context().Done()
+			conn.Close()
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	srv.RegisterOnShutdown(tracker.Close)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/movies", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assert.Equal(t, 1, tracker.Active())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The open connection is closed when shutdown starts, instead of being waited for
+	start := time.Now()
+	assert.NoError(t, srv.Shutdown(ctx))
+	assert.NoError(t, tracker.Wait(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 0, tracker.Active())
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	if netErr, ok := err.(net.Error); assert.Error(t, err) && ok {
+		assert.False(t, netErr.Timeout(), "connection still open")
+	}
+
+	// Upgrades are refused afterwards
+	req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+	req.Header.Set("Upgrade", "websocket")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestWebsocketAuthentication(t *testing.T) {
+	a := newAuthTestApp(t, nil)
+	srv := httptest.NewServer(a.Router)
+	defer srv.Close()
+
+	// init opens a connection without headers, as browsers do, and sends payload in
+	// connection_init, returning the reply type
+	init := func(payload map[string]interface{}) (*websocket.Conn, string) {
+		dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/movies", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		var reply struct{ Type string }
+		assert.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init", "payload": payload}))
+		assert.NoError(t, conn.ReadJSON(&reply))
+		return conn, reply.Type
+	}
+
+	conn, reply := init(map[string]interface{}{"Authorization": bearer(t, "neo", "viewer").Get("Authorization")})
+	assert.Equal(t, "connection_ack", reply)
+
+	assert.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":      "1",
+		"type":    "start",
+		"payload": map[string]interface{}{"query": "{ me { subject } }"},
+	}))
+	var data struct {
+		Type    string
+		Payload struct {
+			Data struct{ Me struct{ Subject string } }
+		}
+	}
+	for data.Type != "data" {
+		assert.NoError(t, conn.ReadJSON(&data))
+	}
+	assert.Equal(t, "neo", data.Payload.Data.Me.Subject)
+
+	_, reply = init(map[string]interface{}{"Authorization": "Bearer invalid"})
+	assert.Equal(t, "connection_error", reply)
+
+	// Anonymous access is allowed by the test app
+	_, reply = init(nil)
+	assert.Equal(t, "connection_ack", reply)
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+)
+
+// APIKeyHeader is the header carrying API keys
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix starts every API key, so they are easy to recognize (e.g. by secret scanners)
+const apiKeyPrefix = "gnk_"
+
+// touchInterval is the minimum time between two updates of the last use of a key
+const touchInterval = time.Minute
+
+var (
+	errInvalidKey = errors.New("invalid API key")
+	errRevokedKey = errors.New("revoked API key")
+	errExpiredKey = errors.New("expired API key")
+	// ErrKeyNotFound is returned when revoking an unknown API key
+	ErrKeyNotFound = errors.New("API key not found")
+	// ErrKeyNameRequired is returned when issuing an API key without name
+	ErrKeyNameRequired = errors.New("API key name is required")
+)
+
+// APIKey is an API key issued to a service-to-service client. Only a hash of the key
+// secret is stored, the key itself is returned once, when it is issued.
+type APIKey struct {
+	// ID identifies the key, it is part of the key itself
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hash is the hex encoded SHA-256 of the key secret
+	Hash string `json:"hash"`
+	// Scopes are granted to clients as roles
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// KeyStore persists API keys
+type KeyStore interface {
+	CreateAPIKey(ctx context.Context, k *APIKey) error
+	// FindAPIKey returns nil (and no error) when the key does not exist
+	FindAPIKey(ctx context.Context, id string) (*APIKey, error)
+	// ListAPIKeys returns every key, revoked ones included, ordered by creation time
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	// TouchAPIKey stores the last use time of a key, and nothing else, so a concurrent
+	// revocation is never overwritten
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	// RevokeAPIKey stores the revocation time of a key, unless it is already revoked, and
+	// returns the key. It returns nil (and no error) when the key does not exist.
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) (*APIKey, error)
+}
+
+// APIKeys issues, revokes and authenticates API keys
+type APIKeys struct {
+	store  KeyStore
+	logger *logger.Entry
+	now    func() time.Time
+}
+
+// NewAPIKeys returns API keys stored in s, logging to l (the default logger if nil)
+func NewAPIKeys(s KeyStore, l *logger.Entry) *APIKeys {
+	return &APIKeys{store: s, logger: l, now: time.Now}
+}
+
+// Issue creates a key granting scopes, that never expires if expiresAt is nil. It returns
+// the key, which cannot be recovered afterwards, and its stored version.
+func (k *APIKeys) Issue(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (string, *APIKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, ErrKeyNameRequired
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+
+	if expiresAt != nil {
+		t := expiresAt.UTC().Truncate(time.Second)
+		expiresAt = &t
+	}
+
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	key := &APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hash:      hashSecret(encodedSecret),
+		Scopes:    scopes,
+		CreatedAt: k.now().UTC().Truncate(time.Second),
+		ExpiresAt: expiresAt,
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	if err := k.store.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+
+	k.logger.WithContext(ctx).Info("API key issued", logger.LogFields{"key_id": key.ID, "name": name, "scopes": scopes})
+
+	return apiKeyPrefix + key.ID + "." + encodedSecret, key, nil
+}
+
+// Revoke revokes a key, returning ErrKeyNotFound if it does not exist
+func (k *APIKeys) Revoke(ctx context.Context, id string) (*APIKey, error) {
+	now := k.now().UTC().Truncate(time.Second)
+	key, err := k.store.RevokeAPIKey(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	if key.RevokedAt != nil && key.RevokedAt.Equal(now) {
+		k.logger.WithContext(ctx).Info("API key revoked", logger.LogFields{"key_id": key.ID, "name": key.Name})
+	}
+
+	return key, nil
+}
+
+// List returns every key
+func (k *APIKeys) List(ctx context.Context) ([]*APIKey, error) {
+	return k.store.ListAPIKeys(ctx)
+}
+
+// Authenticate validates a key and returns its principal, whose roles are the key scopes
+func (k *APIKeys) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), ".", 2)
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(parts) != 2 {
+		return nil, errInvalidKey
+	}
+
+	stored, err := k.store.FindAPIKey(ctx, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(stored.Hash)) != 1 {
+		return nil, errInvalidKey
+	}
+
+	now := k.now()
+	if stored.RevokedAt != nil {
+		return nil, errRevokedKey
+	}
+	if stored.ExpiresAt != nil && !now.Before(*stored.ExpiresAt) {
+		return nil, errExpiredKey
+	}
+
+	k.touch(ctx, stored, now)
+
+	return &Principal{
+		Subject: "apikey:" + stored.ID,
+		Roles:   stored.Scopes,
+		Claims:  map[string]interface{}{"key_id": stored.ID, "name": stored.Name},
+	}, nil
+}
+
+// touch records the last use of a key, at most once every touchInterval so busy
+// clients do not cause a write per request
+func (k *APIKeys) touch(ctx context.Context, key *APIKey, now time.Time) {
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < touchInterval {
+		return
+	}
+
+	used := now.UTC().Truncate(time.Second)
+	key.LastUsedAt = &used
+	if err := k.store.TouchAPIKey(ctx, key.ID, used); err != nil {
+		k.logger.WithContext(ctx).Warning("Cannot update API key last use", err, logger.LogFields{"key_id": key.ID})
+	}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
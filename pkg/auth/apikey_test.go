@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// tempKeyFile returns the path of an API keys file in a temporary directory
+func tempKeyFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "apikeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "api_keys.json")
+}
+
+func newTestAPIKeys(t *testing.T, path string, now *time.Time) *APIKeys {
+	store, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewAPIKeys(store, logger.New(logger.NewCaptureSink()))
+	k.now = func() time.Time { return *now }
+
+	return k
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	path := tempKeyFile(t)
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestAPIKeys(t, path, &now)
+
+	expiresAt := now.Add(24 * time.Hour)
+	key, issued, err := keys.Issue(ctx, "batch", []string{"viewer"}, &expiresAt)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(key, "gnk_"+issued.ID+"."))
+	assert.NotContains(t, issued.Hash, strings.Split(key, ".")[1])
+	assert.Equal(t, now, issued.CreatedAt)
+
+	_, _, err = keys.Issue(ctx, " ", nil, nil)
+	assert.NotNil(t, err)
+
+	p, err := keys.Authenticate(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{
+		Subject: "apikey:" + issued.ID,
+		Roles:   []string{"viewer"},
+		Claims:  map[string]interface{}{"key_id": issued.ID, "name": "batch"},
+	}, p)
+
+	for name, invalid := range map[string]string{
+		"wrong secret": "gnk_" + issued.ID + ".secret",
+		"unknown id":   "gnk_0000000000000000." + strings.Split(key, ".")[1],
+		"no prefix":    strings.TrimPrefix(key, "gnk_"),
+		"no secret":    "gnk_" + issued.ID,
+	} {
+		_, err := keys.Authenticate(ctx, invalid)
+		assert.Equal(t, errInvalidKey, err, name)
+	}
+
+	// Keys, with their last use, are persisted
+	listed, err := newTestAPIKeys(t, path, &now).List(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, "batch", listed[0].Name)
+		assert.Equal(t, &expiresAt, listed[0].ExpiresAt)
+		assert.Equal(t, &now, listed[0].LastUsedAt)
+	}
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Last use is updated at most once a minute
+	used := now
+	now = now.Add(30 * time.Second)
+	_, err = keys.Authenticate(ctx, key)
+	assert.Nil(t, err)
+	listed, _ = keys.List(ctx)
+	assert.Equal(t, &used, listed[0].LastUsedAt)
+
+	now = now.Add(time.Minute)
+	_, err = keys.Authenticate(ctx, key)
+	assert.Nil(t, err)
+	listed, _ = keys.List(ctx)
+	assert.Equal(t, &now, listed[0].LastUsedAt)
+
+	now = expiresAt
+	_, err = keys.Authenticate(ctx, key)
+	assert.Equal(t, errExpiredKey, err)
+}
+
+func TestAPIKeysRevoke(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	keys := newTestAPIKeys(t, tempKeyFile(t), &now)
+
+	key, issued, err := keys.Issue(ctx, "batch", []string{"viewer"}, nil)
+	assert.Nil(t, err)
+	now = now.Add(time.Second)
+	_, other, err := keys.Issue(ctx, "reports", nil, nil)
+	assert.Nil(t, err)
+
+	revoked, err := keys.Revoke(ctx, issued.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, &now, revoked.RevokedAt)
+
+	_, err = keys.Authenticate(ctx, key)
+	assert.Equal(t, errRevokedKey, err)
+
+	_, err = keys.Revoke(ctx, "0000000000000000")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	listed, err := keys.List(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, listed, 2) {
+		// Ordered by creation time
+		assert.Equal(t, issued.ID, listed[0].ID)
+		assert.NotNil(t, listed[0].RevokedAt)
+		assert.Equal(t, other.ID, listed[1].ID)
+		assert.Equal(t, []string{}, listed[1].Scopes)
+		assert.Nil(t, listed[1].RevokedAt)
+	}
+}
+
+// revokingStore runs revoke after finding a key, like a revocation concurrent to the
+// authentication of a request
+type revokingStore struct {
+	*FileKeyStore
+	revoke func()
+}
+
+func (s revokingStore) FindAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	k, err := s.FileKeyStore.FindAPIKey(ctx, id)
+	s.revoke()
+	return k, err
+}
+
+func TestAPIKeysConcurrentRevoke(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	store, err := NewFileKeyStore(tempKeyFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := NewAPIKeys(store, logger.New(logger.NewCaptureSink()))
+	admin.now = func() time.Time { return now }
+
+	key, issued, err := admin.Issue(ctx, "batch", []string{"viewer"}, nil)
+	assert.Nil(t, err)
+
+	// The key is revoked after being loaded, but before its last use is stored
+	keys := NewAPIKeys(revokingStore{FileKeyStore: store, revoke: func() {
+		if _, err := admin.Revoke(ctx, issued.ID); err != nil {
+			t.Fatal(err)
+		}
+	}}, logger.New(logger.NewCaptureSink()))
+	keys.now = func() time.Time { return now.Add(time.Minute) }
+
+	_, err = keys.Authenticate(ctx, key)
+	assert.Nil(t, err)
+
+	// Storing the last use does not undo the revocation
+	stored, err := store.FindAPIKey(ctx, issued.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, &now, stored.RevokedAt)
+	used := now.Add(time.Minute)
+	assert.Equal(t, &used, stored.LastUsedAt)
+
+	_, err = keys.Authenticate(ctx, key)
+	assert.Equal(t, errRevokedKey, err)
+
+	// Revoking again keeps the first revocation time
+	now = now.Add(time.Hour)
+	revoked, err := admin.Revoke(ctx, issued.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, stored.RevokedAt, revoked.RevokedAt)
+	assert.Equal(t, &used, revoked.LastUsedAt)
+}
+
+// failingStore fails to find keys
+type failingStore struct {
+	KeyStore
+}
+
+func (failingStore) FindAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	return nil, errors.New("Neo4j unavailable")
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	now := time.Now()
+	keys := newTestAPIKeys(t, tempKeyFile(t), &now)
+	key, _, err := keys.Issue(context.Background(), "batch", []string{"viewer"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var principal *Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = FromContext(r.Context())
+	})
+
+	tests := []struct {
+		name    string
+		keys    *APIKeys
+		header  map[string]string
+		status  int
+		subject string
+	}{
+		{name: "valid key", keys: keys, header: map[string]string{APIKeyHeader: key}, status: http.StatusOK, subject: "apikey:"},
+		{name: "invalid key", keys: keys, header: map[string]string{APIKeyHeader: key + "x"}, status: http.StatusUnauthorized},
+		{name: "key and token", keys: keys, header: map[string]string{APIKeyHeader: key, "Authorization": "Bearer invalid"}, status: http.StatusOK, subject: "apikey:"},
+		{name: "anonymous", keys: keys, status: http.StatusOK},
+		{name: "store failure", keys: NewAPIKeys(failingStore{}, nil), header: map[string]string{APIKeyHeader: key}, status: http.StatusServiceUnavailable},
+		{name: "keys disabled", header: map[string]string{APIKeyHeader: key}, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			a, err := NewAuthenticator(Config{Secret: secret, AllowAnonymous: true, APIKeys: tt.keys}, logger.New(logger.NewCaptureSink()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			a.Middleware(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.subject == "" {
+				assert.Nil(t, principal)
+			} else if assert.NotNil(t, principal) {
+				assert.True(t, strings.HasPrefix(principal.Subject, tt.subject))
+				assert.Equal(t, []string{"viewer"}, principal.Roles)
+			}
+		})
+	}
+
+	// Websocket connections can send their key in the connection_init payload
+	a, _ := NewAuthenticator(Config{APIKeys: keys}, logger.New(logger.NewCaptureSink()))
+	ctx, err := a.WebsocketInit(context.Background(), transport.InitPayload{APIKeyHeader: key})
+	assert.Nil(t, err)
+	if p := FromContext(ctx); assert.NotNil(t, p) {
+		assert.True(t, strings.HasPrefix(p.Subject, "apikey:"))
+	}
+	_, err = a.WebsocketInit(context.Background(), transport.InitPayload{APIKeyHeader: key + "x"})
+	assert.NotNil(t, err)
+}
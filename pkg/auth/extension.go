@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ReadOnly is a gqlgen extension that rejects mutations from anonymous requests
+type ReadOnly struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = ReadOnly{}
+
+// ExtensionName returns the extension name
+func (ReadOnly) ExtensionName() string {
+	return "ReadOnly"
+}
+
+// Validate is a no-op, the extension works with any schema
+func (ReadOnly) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation returns an error instead of executing mutations without a principal
+func (ReadOnly) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation != nil && oc.Operation.Operation == ast.Mutation && FromContext(ctx) == nil {
+		return graphql.OneShot(&graphql.Response{Errors: gqlerror.List{{
+			Message:    "authentication required",
+			Extensions: map[string]interface{}{"code": "UNAUTHENTICATED"},
+		}}})
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileKeyStore stores API keys in a JSON file, {"keys": [...]}, rewritten on every change
+type FileKeyStore struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string]*APIKey
+}
+
+var _ KeyStore = &FileKeyStore{}
+
+// NewFileKeyStore loads the keys stored in path, which is created on the first change
+// if it does not exist
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path, keys: map[string]*APIKey{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read API keys file: %w", err)
+	}
+
+	var file struct {
+		Keys []*APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid API keys file %s: %w", path, err)
+	}
+
+	for _, k := range file.Keys {
+		s.keys[k.ID] = k
+	}
+
+	return s, nil
+}
+
+// CreateAPIKey stores a new key
+func (s *FileKeyStore) CreateAPIKey(ctx context.Context, k *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[k.ID]; ok {
+		return fmt.Errorf("duplicate API key id: %s", k.ID)
+	}
+
+	c := *k
+	s.keys[k.ID] = &c
+
+	return s.save()
+}
+
+// FindAPIKey finds a key by its id
+func (s *FileKeyStore) FindAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, nil
+	}
+
+	c := *k
+	return &c, nil
+}
+
+// ListAPIKeys returns every key, ordered by creation time
+func (s *FileKeyStore) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sorted(), nil
+}
+
+// TouchAPIKey stores the last use time of a key
+func (s *FileKeyStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	stored.LastUsedAt = &at
+
+	return s.save()
+}
+
+// RevokeAPIKey stores the revocation time of a key, unless it is already revoked
+func (s *FileKeyStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if stored.RevokedAt == nil {
+		stored.RevokedAt = &at
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := *stored
+	return &c, nil
+}
+
+// sorted returns copies of the keys, ordered by creation time and id
+func (s *FileKeyStore) sorted() []*APIKey {
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		c := *k
+		keys = append(keys, &c)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
+
+// save writes the keys to a temporary file that replaces the store file, so it is
+// never left half written. Key hashes are secrets, the file is only readable by its owner.
+func (s *FileKeyStore) save() error {
+	data, err := json.MarshalIndent(map[string]interface{}{"keys": s.sorted()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
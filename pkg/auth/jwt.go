@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	jwt "github.com/golang-jwt/jwt"
+)
+
+// Config configures authentication. At least one of Secret, JWKSFile and APIKeys must be set.
+type Config struct {
+	// Secret verifies HS256 tokens
+	Secret string
+	// JWKSFile is a JSON Web Key Set file with the RSA public keys verifying RS256 tokens
+	JWKSFile string
+	// Issuer and Audience, when set, must match the "iss" and "aud" claims
+	Issuer   string
+	Audience string
+	// RolesClaim is the claim holding the principal roles, either a list or a space
+	// separated string (default "roles")
+	RolesClaim string
+	// AllowAnonymous lets requests without a token through, read-only (see ReadOnly)
+	AllowAnonymous bool
+	// APIKeys, when set, authenticates requests carrying an X-API-Key header
+	APIKeys *APIKeys
+}
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errUnknownKey   = errors.New("unknown signing key")
+	errNotBearer    = errors.New("not a bearer token")
+	errUnavailable  = errors.New("service unavailable")
+)
+
+// Authenticator validates JWT bearer tokens and API keys
+type Authenticator struct {
+	config Config
+	secret []byte
+	keys   map[string]*rsa.PublicKey
+	logger *logger.Entry
+}
+
+// NewAuthenticator creates an authenticator logging to l (the default logger if nil),
+// loading the JWKS file, if any
+func NewAuthenticator(c Config, l *logger.Entry) (*Authenticator, error) {
+	if c.Secret == "" && c.JWKSFile == "" && c.APIKeys == nil {
+		return nil, errors.New("authentication requires a JWT secret, a JWKS file or API keys")
+	}
+	if c.RolesClaim == "" {
+		c.RolesClaim = "roles"
+	}
+
+	a := &Authenticator{
+		config: c,
+		secret: []byte(c.Secret),
+		keys:   map[string]*rsa.PublicKey{},
+		logger: l,
+	}
+
+	if c.JWKSFile != "" {
+		keys, err := loadJWKS(c.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+
+	return a, nil
+}
+
+// jwks is a JSON Web Key Set, as defined by RFC 7517
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS reads the RSA signing keys of a JWKS file, indexed by key id
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read JWKS file: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %s: %w", path, err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA signing keys found in %s", path)
+	}
+
+	return keys, nil
+}
+
+// key returns the key verifying token, rejecting algorithms that are not configured
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if len(a.secret) == 0 {
+			break
+		}
+		return a.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.keys[kid]; ok {
+			return key, nil
+		}
+		// Tokens without key id are accepted when there is a single key
+		if kid == "" && len(a.keys) == 1 {
+			for _, key := range a.keys {
+				return key, nil
+			}
+		}
+		return nil, errUnknownKey
+	}
+
+	return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+}
+
+// Authenticate validates a token (signature, expiration, issuer and audience) and
+// returns its principal
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}}
+
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(token, claims, a.key); err != nil {
+		return nil, err
+	}
+
+	if a.config.Issuer != "" && !claims.VerifyIssuer(a.config.Issuer, true) {
+		return nil, errors.New("invalid issuer")
+	}
+	if a.config.Audience != "" && !hasAudience(claims, a.config.Audience) {
+		return nil, errors.New("invalid audience")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("missing subject")
+	}
+
+	return &Principal{
+		Subject: subject,
+		Roles:   stringList(claims[a.config.RolesClaim]),
+		Claims:  claims,
+	}, nil
+}
+
+// hasAudience checks the "aud" claim, that can be a string or a list
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	for _, aud := range stringList(claims["aud"]) {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringList returns a list claim, or a space separated string claim, as a slice
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		res := []string{}
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+
+	return nil
+}
+
+// Middleware authenticates requests carrying an API key (if enabled) or an
+// "Authorization: Bearer" header and stores the principal in the request context.
+// Requests with invalid credentials are rejected, and so are requests without any
+// unless anonymous access is allowed. Websocket upgrades without credentials are let
+// through, browsers being unable to set headers on them: they authenticate with the
+// connection_init payload instead (see WebsocketInit).
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.authenticate(r.Context(), r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+		if err == errMissingToken && (a.config.AllowAnonymous || r.Header.Get("Upgrade") != "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err == errUnavailable {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			a.unauthorized(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+// WebsocketInit is a gqlgen websocket init function authenticating connections that
+// were not authenticated by the upgrade request, with the "Authorization" or "X-API-Key"
+// entry of their connection_init payload. Connections without credentials are refused
+// unless anonymous access is allowed.
+func (a *Authenticator) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+	if FromContext(ctx) != nil {
+		return ctx, nil
+	}
+
+	p, err := a.authenticate(ctx, payloadString(payload, APIKeyHeader), payloadString(payload, "Authorization"))
+	if err == errMissingToken && a.config.AllowAnonymous {
+		return ctx, nil
+	}
+	if err == errUnavailable {
+		return nil, err
+	}
+	if err != nil {
+		a.logger.WithContext(ctx).Debug("Authentication failed", logger.LogFields{"reason": err.Error()})
+		return nil, errors.New("unauthorized")
+	}
+
+	return NewContext(ctx, p), nil
+}
+
+// payloadString returns the string entry of a connection_init payload, whose keys are
+// compared case insensitively as clients spell header names differently
+func payloadString(payload transport.InitPayload, key string) string {
+	for k, v := range payload {
+		if s, ok := v.(string); ok && strings.EqualFold(k, key) {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// authenticate returns the principal of an API key (if enabled) or, when there is none,
+// of an "Authorization: Bearer" header value. It returns errMissingToken without
+// credentials, and errUnavailable when API keys cannot be checked.
+func (a *Authenticator) authenticate(ctx context.Context, key, authorization string) (*Principal, error) {
+	if key != "" && a.config.APIKeys != nil {
+		p, err := a.config.APIKeys.Authenticate(ctx, key)
+		if err != nil && err != errInvalidKey && err != errRevokedKey && err != errExpiredKey {
+			a.logger.WithContext(ctx).Error("Cannot authenticate API key", err)
+			return nil, errUnavailable
+		}
+		return p, err
+	}
+
+	if authorization == "" {
+		return nil, errMissingToken
+	}
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errNotBearer
+	}
+
+	return a.Authenticate(strings.TrimPrefix(authorization, "Bearer "))
+}
+
+func (a *Authenticator) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	a.logger.WithContext(r.Context()).Debug("Authentication failed", logger.LogFields{"reason": err.Error()})
+
+	if err == errMissingToken || err == errNotBearer {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="movies"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="movies", error="invalid_token"`)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const secret = "s3cr3t"
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{"sub": "neo", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range extra {
+		c[k] = v
+	}
+
+	return c
+}
+
+// writeJWKS writes the public part of keys to a JWKS file
+func writeJWKS(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	set := map[string][]map[string]string{"keys": {}}
+	for kid, k := range keys {
+		set["keys"] = append(set["keys"], map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestAuthenticateHS256(t *testing.T) {
+	a, err := NewAuthenticator(Config{Secret: secret, Issuer: "movies", Audience: "api"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Authenticate(sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{
+		"iss":   "movies",
+		"aud":   []string{"web", "api"},
+		"roles": []string{"admin", "editor"},
+	})))
+	assert.Nil(t, err)
+	assert.Equal(t, "neo", p.Subject)
+	assert.Equal(t, []string{"admin", "editor"}, p.Roles)
+	assert.True(t, p.HasRole("editor"))
+	assert.False(t, p.HasRole("viewer"))
+	assert.Equal(t, "movies", p.Claims["iss"])
+
+	for name, token := range map[string]string{
+		"expired":        sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"iss": "movies", "aud": "api", "exp": time.Now().Add(-time.Minute).Unix()})),
+		"not yet valid":  sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"iss": "movies", "aud": "api", "nbf": time.Now().Add(time.Minute).Unix()})),
+		"wrong secret":   sign(t, jwt.SigningMethodHS256, []byte("other"), "", claims(jwt.MapClaims{"iss": "movies", "aud": "api"})),
+		"wrong issuer":   sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"iss": "other", "aud": "api"})),
+		"wrong audience": sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"iss": "movies", "aud": "web"})),
+		"no subject":     sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"iss": "movies", "aud": "api"}),
+		"HS512":          sign(t, jwt.SigningMethodHS512, []byte(secret), "", claims(jwt.MapClaims{"iss": "movies", "aud": "api"})),
+		"none":           sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims(jwt.MapClaims{"iss": "movies", "aud": "api"})),
+		"RS256":          sign(t, jwt.SigningMethodRS256, rsaKey(t), "", claims(jwt.MapClaims{"iss": "movies", "aud": "api"})),
+		"malformed":      "not.a.token",
+	} {
+		_, err := a.Authenticate(token)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestAuthenticateRS256(t *testing.T) {
+	k1, k2 := rsaKey(t), rsaKey(t)
+	a, err := NewAuthenticator(Config{JWKSFile: writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2}), RolesClaim: "scope"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for kid, key := range map[string]*rsa.PrivateKey{"k1": k1, "k2": k2} {
+		p, err := a.Authenticate(sign(t, jwt.SigningMethodRS256, key, kid, claims(jwt.MapClaims{"scope": "read write"})))
+		assert.Nil(t, err, kid)
+		assert.Equal(t, []string{"read", "write"}, p.Roles, kid)
+	}
+
+	// Key ids must match, and are required when there are several keys
+	for name, token := range map[string]string{
+		"wrong key":   sign(t, jwt.SigningMethodRS256, k2, "k1", claims(nil)),
+		"unknown kid": sign(t, jwt.SigningMethodRS256, k1, "k3", claims(nil)),
+		"no kid":      sign(t, jwt.SigningMethodRS256, k1, "", claims(nil)),
+		"HS256":       sign(t, jwt.SigningMethodHS256, []byte(""), "", claims(nil)),
+	} {
+		_, err := a.Authenticate(token)
+		assert.NotNil(t, err, name)
+	}
+
+	single, err := NewAuthenticator(Config{JWKSFile: writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1})}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = single.Authenticate(sign(t, jwt.SigningMethodRS256, k1, "", claims(nil)))
+	assert.Nil(t, err)
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	_, err := NewAuthenticator(Config{}, nil)
+	assert.NotNil(t, err)
+
+	_, err = NewAuthenticator(Config{JWKSFile: "missing.json"}, nil)
+	assert.NotNil(t, err)
+
+	_, err = NewAuthenticator(Config{JWKSFile: writeJWKS(t, nil)}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	sink := logger.NewCaptureSink()
+
+	var principal *Principal
+	var called bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		principal = FromContext(r.Context())
+		logger.New(sink).WithContext(r.Context()).Info("served")
+	})
+
+	valid := "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(nil))
+	expired := "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"exp": 1}))
+
+	tests := []struct {
+		name          string
+		anonymous     bool
+		upgrade       bool
+		authorization string
+		status        int
+		subject       string
+	}{
+		{name: "valid token", authorization: valid, status: http.StatusOK, subject: "neo"},
+		{name: "missing token", status: http.StatusUnauthorized},
+		{name: "expired token", authorization: expired, status: http.StatusUnauthorized},
+		{name: "basic auth", authorization: "Basic bmVvOnRyaW5pdHk=", status: http.StatusUnauthorized},
+		{name: "anonymous", anonymous: true, status: http.StatusOK},
+		{name: "anonymous with valid token", anonymous: true, authorization: valid, status: http.StatusOK, subject: "neo"},
+		{name: "anonymous with expired token", anonymous: true, authorization: expired, status: http.StatusUnauthorized},
+		// Websocket connections can authenticate with their connection_init payload
+		{name: "upgrade", upgrade: true, status: http.StatusOK},
+		{name: "upgrade with valid token", upgrade: true, authorization: valid, status: http.StatusOK, subject: "neo"},
+		{name: "upgrade with expired token", upgrade: true, authorization: expired, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, called = nil, false
+			a, err := NewAuthenticator(Config{Secret: secret, AllowAnonymous: tt.anonymous}, logger.New(logger.NewCaptureSink()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.upgrade {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			rec := httptest.NewRecorder()
+
+			a.Middleware(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+				assert.False(t, called)
+				return
+			}
+
+			assert.True(t, called)
+			if tt.subject == "" {
+				assert.Nil(t, principal)
+				return
+			}
+
+			if assert.NotNil(t, principal) {
+				assert.Equal(t, tt.subject, principal.Subject)
+			}
+			entries := sink.Find("served")
+			if assert.NotEmpty(t, entries) {
+				assert.Equal(t, tt.subject, entries[len(entries)-1].Fields[logger.FieldUser])
+			}
+		})
+	}
+}
+
+func TestWebsocketInit(t *testing.T) {
+	ctx := context.Background()
+	valid := "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(nil))
+	expired := "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims(jwt.MapClaims{"exp": 1}))
+
+	tests := []struct {
+		name      string
+		anonymous bool
+		payload   transport.InitPayload
+		err       bool
+		subject   string
+	}{
+		{name: "valid token", payload: transport.InitPayload{"Authorization": valid}, subject: "neo"},
+		{name: "lower case key", payload: transport.InitPayload{"authorization": valid}, subject: "neo"},
+		{name: "expired token", payload: transport.InitPayload{"Authorization": expired}, err: true},
+		{name: "basic auth", payload: transport.InitPayload{"Authorization": "Basic bmVvOnRyaW5pdHk="}, err: true},
+		{name: "missing token", err: true},
+		{name: "anonymous", anonymous: true},
+		{name: "anonymous with expired token", anonymous: true, payload: transport.InitPayload{"Authorization": expired}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAuthenticator(Config{Secret: secret, AllowAnonymous: tt.anonymous}, logger.New(logger.NewCaptureSink()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			initCtx, err := a.WebsocketInit(ctx, tt.payload)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			if tt.subject == "" {
+				assert.Nil(t, FromContext(initCtx))
+			} else if assert.NotNil(t, FromContext(initCtx)) {
+				assert.Equal(t, tt.subject, FromContext(initCtx).Subject)
+			}
+		})
+	}
+
+	// Connections authenticated by the upgrade request are kept as they are
+	a, _ := NewAuthenticator(Config{Secret: secret}, logger.New(logger.NewCaptureSink()))
+	authenticated := NewContext(ctx, &Principal{Subject: "trinity"})
+	initCtx, err := a.WebsocketInit(authenticated, transport.InitPayload{"Authorization": expired})
+	assert.Nil(t, err)
+	assert.Equal(t, authenticated, initCtx)
+}
+
+func TestReadOnly(t *testing.T) {
+	next := func(ctx context.Context) graphql.ResponseHandler {
+		return graphql.OneShot(&graphql.Response{Data: []byte(`{"ok":true}`)})
+	}
+
+	for _, tt := range []struct {
+		operation ast.Operation
+		principal *Principal
+		allowed   bool
+	}{
+		{operation: ast.Query, allowed: true},
+		{operation: ast.Subscription, allowed: true},
+		{operation: ast.Mutation},
+		{operation: ast.Mutation, principal: &Principal{Subject: "neo"}, allowed: true},
+	} {
+		ctx := graphql.WithOperationContext(context.Background(), &graphql.OperationContext{
+			Operation: &ast.OperationDefinition{Operation: tt.operation},
+		})
+		if tt.principal != nil {
+			ctx = NewContext(ctx, tt.principal)
+		}
+
+		resp := ReadOnly{}.InterceptOperation(ctx, next)(ctx)
+
+		if tt.allowed {
+			assert.Empty(t, resp.Errors, tt.operation)
+			assert.JSONEq(t, `{"ok":true}`, string(resp.Data))
+		} else {
+			if assert.Len(t, resp.Errors, 1, tt.operation) {
+				assert.Equal(t, "authentication required", resp.Errors[0].Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package auth authenticates API requests and stores the authenticated principal in
+// the request context, where resolvers and the logger can find it.
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Subject identifies the caller (the "sub" claim of a JWT)
+	Subject string
+	Roles   []string
+	// Claims holds every claim of the token the principal was authenticated with
+	Claims map[string]interface{}
+}
+
+// HasRole tells whether the principal was granted role, compared case insensitively
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying p. The principal subject is also added
+// to the log context, so every entry logged while serving the request carries it.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	ctx = logger.NewContext(ctx, logger.LogFields{logger.FieldUser: p.Subject})
+
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, or nil for anonymous requests
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
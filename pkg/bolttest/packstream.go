@@ -0,0 +1,350 @@
+package bolttest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Structure is a PackStream structure, as used by Bolt messages
+type Structure struct {
+	Signature byte
+	Fields    []interface{}
+}
+
+// Node is a graph node that can be returned in records
+type Node struct {
+	ID     int64
+	Labels []string
+	Props  map[string]interface{}
+}
+
+// packer writes PackStream values
+type packer struct {
+	buf bytes.Buffer
+}
+
+func (p *packer) marker(tiny byte, size int, m8, m16, m32 byte) error {
+	switch {
+	case size < 0x10 && tiny != 0:
+		p.buf.WriteByte(tiny | byte(size))
+	case size <= math.MaxUint8 && m8 != 0:
+		p.buf.WriteByte(m8)
+		p.buf.WriteByte(byte(size))
+	case size <= math.MaxUint16:
+		p.buf.WriteByte(m16)
+		binary.Write(&p.buf, binary.BigEndian, uint16(size))
+	case size <= math.MaxUint32:
+		p.buf.WriteByte(m32)
+		binary.Write(&p.buf, binary.BigEndian, uint32(size))
+	default:
+		return fmt.Errorf("value too large: %d", size)
+	}
+
+	return nil
+}
+
+func (p *packer) int(i int64) {
+	switch {
+	case i >= -16 && i <= 127:
+		p.buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		p.buf.WriteByte(0xC8)
+		p.buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		p.buf.WriteByte(0xC9)
+		binary.Write(&p.buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		p.buf.WriteByte(0xCA)
+		binary.Write(&p.buf, binary.BigEndian, int32(i))
+	default:
+		p.buf.WriteByte(0xCB)
+		binary.Write(&p.buf, binary.BigEndian, i)
+	}
+}
+
+func (p *packer) string(s string) error {
+	if err := p.marker(0x80, len(s), 0xD0, 0xD1, 0xD2); err != nil {
+		return err
+	}
+	p.buf.WriteString(s)
+
+	return nil
+}
+
+func (p *packer) structure(s Structure) error {
+	if len(s.Fields) >= 0x10 {
+		return fmt.Errorf("too many structure fields: %d", len(s.Fields))
+	}
+
+	p.buf.WriteByte(0xB0 | byte(len(s.Fields)))
+	p.buf.WriteByte(s.Signature)
+	for _, f := range s.Fields {
+		if err := p.pack(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pack writes v, which can be nil, a boolean, any integer or float, a string, []byte,
+// a slice, a map with string keys, a Node or a Structure
+func (p *packer) pack(v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		p.buf.WriteByte(0xC0)
+		return nil
+	case bool:
+		if t {
+			p.buf.WriteByte(0xC3)
+		} else {
+			p.buf.WriteByte(0xC2)
+		}
+		return nil
+	case string:
+		return p.string(t)
+	case []byte:
+		if err := p.marker(0, len(t), 0xCC, 0xCD, 0xCE); err != nil {
+			return err
+		}
+		p.buf.Write(t)
+		return nil
+	case Structure:
+		return p.structure(t)
+	case Node:
+		labels := make([]interface{}, len(t.Labels))
+		for i, l := range t.Labels {
+			labels[i] = l
+		}
+		props := t.Props
+		if props == nil {
+			props = map[string]interface{}{}
+		}
+		return p.structure(Structure{Signature: 'N', Fields: []interface{}{t.ID, labels, props}})
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return fmt.Errorf("integer overflow: %d", rv.Uint())
+		}
+		p.int(int64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		p.buf.WriteByte(0xC1)
+		binary.Write(&p.buf, binary.BigEndian, rv.Float())
+	case reflect.Slice, reflect.Array:
+		if err := p.marker(0x90, rv.Len(), 0xD4, 0xD5, 0xD6); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := p.pack(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type: %s", rv.Type().Key())
+		}
+		if err := p.marker(0xA0, rv.Len(), 0xD8, 0xD9, 0xDA); err != nil {
+			return err
+		}
+
+		// Sorted, so packed maps can be compared
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if err := p.string(k.String()); err != nil {
+				return err
+			}
+			if err := p.pack(rv.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			p.buf.WriteByte(0xC0)
+			return nil
+		}
+		return p.pack(rv.Elem().Interface())
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+
+	return nil
+}
+
+// unpacker reads PackStream values
+type unpacker struct {
+	r *bytes.Reader
+}
+
+func (u *unpacker) size(n int) (int, error) {
+	switch n {
+	case 1:
+		b, err := u.r.ReadByte()
+		return int(b), err
+	case 2:
+		var s uint16
+		err := binary.Read(u.r, binary.BigEndian, &s)
+		return int(s), err
+	default:
+		var s uint32
+		err := binary.Read(u.r, binary.BigEndian, &s)
+		return int(s), err
+	}
+}
+
+func (u *unpacker) bytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(u.r, b)
+	return b, err
+}
+
+// unpack reads a value. Integers are returned as int64, floats as float64, lists as
+// []interface{}, maps as map[string]interface{} and structures as Structure.
+func (u *unpacker) unpack() (interface{}, error) {
+	m, err := u.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	high := m & 0xF0
+	switch {
+	case m <= 0x7F || m >= 0xF0:
+		return int64(int8(m)), nil
+	case high == 0x80:
+		b, err := u.bytes(int(m & 0x0F))
+		return string(b), err
+	case high == 0x90:
+		return u.list(int(m & 0x0F))
+	case high == 0xA0:
+		return u.dict(int(m & 0x0F))
+	case high == 0xB0:
+		return u.structure(int(m & 0x0F))
+	}
+
+	switch m {
+	case 0xC0:
+		return nil, nil
+	case 0xC1:
+		var f float64
+		err := binary.Read(u.r, binary.BigEndian, &f)
+		return f, err
+	case 0xC2:
+		return false, nil
+	case 0xC3:
+		return true, nil
+	case 0xC8:
+		var i int8
+		err := binary.Read(u.r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xC9:
+		var i int16
+		err := binary.Read(u.r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xCA:
+		var i int32
+		err := binary.Read(u.r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xCB:
+		var i int64
+		err := binary.Read(u.r, binary.BigEndian, &i)
+		return i, err
+	case 0xCC, 0xCD, 0xCE:
+		n, err := u.size(1 << (m - 0xCC))
+		if err != nil {
+			return nil, err
+		}
+		return u.bytes(n)
+	case 0xD0, 0xD1, 0xD2:
+		n, err := u.size(1 << (m - 0xD0))
+		if err != nil {
+			return nil, err
+		}
+		b, err := u.bytes(n)
+		return string(b), err
+	case 0xD4, 0xD5, 0xD6:
+		n, err := u.size(1 << (m - 0xD4))
+		if err != nil {
+			return nil, err
+		}
+		return u.list(n)
+	case 0xD8, 0xD9, 0xDA:
+		n, err := u.size(1 << (m - 0xD8))
+		if err != nil {
+			return nil, err
+		}
+		return u.dict(n)
+	case 0xDC, 0xDD:
+		n, err := u.size(1 << (m - 0xDC))
+		if err != nil {
+			return nil, err
+		}
+		return u.structure(n)
+	}
+
+	return nil, fmt.Errorf("unknown marker: 0x%X", m)
+}
+
+func (u *unpacker) list(n int) ([]interface{}, error) {
+	list := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := u.unpack()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	return list, nil
+}
+
+func (u *unpacker) dict(n int) (map[string]interface{}, error) {
+	dict := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := u.unpack()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid map key: %v", k)
+		}
+
+		v, err := u.unpack()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+	}
+
+	return dict, nil
+}
+
+func (u *unpacker) structure(n int) (Structure, error) {
+	sig, err := u.r.ReadByte()
+	if err != nil {
+		return Structure{}, err
+	}
+
+	fields, err := u.list(n)
+	return Structure{Signature: sig, Fields: fields}, err
+}
+
+// normalize returns v as it would be received by the other end (e.g. any integer as int64)
+func normalize(v interface{}) (interface{}, error) {
+	p := &packer{}
+	if err := p.pack(v); err != nil {
+		return nil, err
+	}
+
+	return (&unpacker{r: bytes.NewReader(p.buf.Bytes())}).unpack()
+}
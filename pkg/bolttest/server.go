@@ -0,0 +1,598 @@
+// Package bolttest provides a scriptable Bolt protocol server, so code using the Neo4j
+// driver can be tested end to end without a database. The server accepts the
+// handshake (Bolt v1 to v3), authentication and transactions, matches every query
+// against the expected Cypher statements and parameters, and answers with canned
+// records, failures or disconnections.
+//
+//   server := bolttest.NewServer(t)
+//   server.Expect("match (m:Movie) where m.uuid = $uuid return m.title", map[string]interface{}{"uuid": "1"}).
+//       Fields("m.title").
+//       Record("The Matrix")
+//   driver, _ := neo4j.NewDriver(server.URI(), neo4j.NoAuth(), func(c *neo4j.Config) { c.Encrypted = false })
+//
+// Statements are compared ignoring white space differences.
+package bolttest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ServerAgent is the server name sent to clients
+const ServerAgent = "Neo4j/3.5.17"
+
+// Bolt message signatures
+const (
+	msgInit       = 0x01 // HELLO in Bolt v3
+	msgGoodbye    = 0x02
+	msgAckFailure = 0x0E
+	msgReset      = 0x0F
+	msgRun        = 0x10
+	msgBegin      = 0x11
+	msgCommit     = 0x12
+	msgRollback   = 0x13
+	msgDiscardAll = 0x2F
+	msgPullAll    = 0x3F
+	msgSuccess    = 0x70
+	msgRecord     = 0x71
+	msgIgnored    = 0x7E
+	msgFailure    = 0x7F
+)
+
+var handshakeMagic = []byte{0x60, 0x60, 0xB0, 0x17}
+
+// CodeUnexpectedQuery is the failure code sent for queries that match no expectation
+const CodeUnexpectedQuery = "Neo.ClientError.Request.Invalid"
+
+// Expectation is an expected query and the response sent when it is received.
+// Expectations are matched in the order they were added.
+type Expectation struct {
+	statement string
+	params    map[string]interface{}
+	times     int
+	matched   int
+
+	fields  []string
+	records [][]interface{}
+	summary map[string]interface{}
+	code    string
+	message string
+	close   bool
+	delay   time.Duration
+}
+
+// Fields sets the names of the returned columns
+func (e *Expectation) Fields(names ...string) *Expectation {
+	e.fields = names
+	return e
+}
+
+// Record adds a returned record, with one value per field
+func (e *Expectation) Record(values ...interface{}) *Expectation {
+	e.records = append(e.records, values)
+	return e
+}
+
+// Summary adds metadata to the result summary (e.g. "plan", "profile" or "stats")
+func (e *Expectation) Summary(metadata map[string]interface{}) *Expectation {
+	e.summary = metadata
+	return e
+}
+
+// Fail makes the query fail with a Neo4j error code (e.g. Neo.TransientError.General.DatabaseUnavailable)
+func (e *Expectation) Fail(code string, message string) *Expectation {
+	e.code = code
+	e.message = message
+	return e
+}
+
+// Disconnect makes the server close the connection when the query is received
+func (e *Expectation) Disconnect() *Expectation {
+	e.close = true
+	return e
+}
+
+// Delay makes the server wait before answering the query
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// Times sets how many times the query is expected (once by default). Zero means any number of times.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s %v", e.statement, e.params)
+}
+
+// Server is a Bolt protocol stub listening on a local port
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+	queries      []string
+	conns        map[net.Conn]struct{}
+	accepted     int
+	closed       bool
+	version      uint32
+}
+
+// NewServer starts a server on a random local port. It is closed, and expectations
+// verified, when the test finishes.
+func NewServer(t testing.TB) *Server {
+	s, err := Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+		s.Verify(t)
+	})
+
+	return s
+}
+
+// Start starts a server on a random local port
+func Start() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{version: 3, listener: l, conns: map[net.Conn]struct{}{}}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// SetVersion sets the highest Bolt version accepted (3 by default)
+func (s *Server) SetVersion(v uint32) {
+	s.mu.Lock()
+	s.version = v
+	s.mu.Unlock()
+}
+
+// URI returns the bolt:// URI of the server
+func (s *Server) URI() string {
+	return "bolt://" + s.listener.Addr().String()
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Expect adds an expected query. Nil params match any parameters.
+func (s *Server) Expect(statement string, params map[string]interface{}) *Expectation {
+	e := &Expectation{statement: normalizeStatement(statement), times: 1}
+	if params != nil {
+		normalized, err := normalize(params)
+		if err != nil {
+			panic(fmt.Sprintf("bolttest: invalid params: %v", err))
+		}
+		e.params = normalized.(map[string]interface{})
+	}
+
+	s.mu.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mu.Unlock()
+
+	return e
+}
+
+// Queries returns the statements received, in order
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.queries...)
+}
+
+// Unexpected returns the queries that did not match any expectation
+func (s *Server) Unexpected() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.unexpected...)
+}
+
+// Pending returns the expectations that were not fully matched
+func (s *Server) Pending() []*Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := []*Expectation{}
+	for _, e := range s.expectations {
+		if e.times > 0 && e.matched < e.times {
+			pending = append(pending, e)
+		}
+	}
+
+	return pending
+}
+
+// Connections returns the number of connections accepted so far
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accepted
+}
+
+// Verify reports unexpected queries and pending expectations as test errors
+func (s *Server) Verify(t testing.TB) {
+	for _, q := range s.Unexpected() {
+		t.Errorf("bolttest: unexpected query: %s", q)
+	}
+
+	for _, e := range s.Pending() {
+		t.Errorf("bolttest: expected query not received: %s (%d/%d times)", e, e.matched, e.times)
+	}
+}
+
+// Close stops the server and closes all connections
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.accepted++
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+				c.Close()
+			}()
+
+			(&conn{server: s, rw: bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))}).serve()
+		}()
+	}
+}
+
+// match finds the first expectation matching a query, recording unexpected ones
+func (s *Server) match(statement string, params map[string]interface{}) *Expectation {
+	statement = normalizeStatement(statement)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, statement)
+
+	for _, e := range s.expectations {
+		if e.times > 0 && e.matched >= e.times {
+			continue
+		}
+		if e.statement != statement {
+			continue
+		}
+		if e.params != nil && !reflect.DeepEqual(e.params, params) {
+			continue
+		}
+
+		e.matched++
+		return e
+	}
+
+	s.unexpected = append(s.unexpected, fmt.Sprintf("%s %v", statement, params))
+	return nil
+}
+
+// conn serves a single client connection
+type conn struct {
+	server  *Server
+	rw      *bufio.ReadWriter
+	version uint32
+	failed  bool
+	result  *Expectation
+}
+
+func (c *conn) serve() {
+	if err := c.handshake(); err != nil {
+		return
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return
+		}
+
+		if !c.handle(msg) {
+			return
+		}
+
+		if err := c.rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handshake reads the magic preamble and the four proposed versions, and answers with
+// the highest version supported by both sides (zero if none)
+func (c *conn) handshake() error {
+	buf := make([]byte, 20)
+	if _, err := io.ReadFull(c.rw, buf); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(buf[:4], handshakeMagic) {
+		return fmt.Errorf("invalid handshake")
+	}
+
+	c.server.mu.Lock()
+	max := c.server.version
+	c.server.mu.Unlock()
+
+	for i := 0; i < 4; i++ {
+		v := binary.BigEndian.Uint32(buf[4+i*4:])
+		if v > 0 && v <= max && v > c.version {
+			c.version = v
+		}
+	}
+
+	binary.Write(c.rw, binary.BigEndian, c.version)
+	if err := c.rw.Flush(); err != nil {
+		return err
+	}
+
+	if c.version == 0 {
+		return fmt.Errorf("no supported version")
+	}
+
+	return nil
+}
+
+// handle answers a message, returning false when the connection must be closed
+func (c *conn) handle(msg Structure) bool {
+	switch msg.Signature {
+	case msgGoodbye:
+		return false
+	case msgReset, msgAckFailure:
+		c.failed = false
+		c.result = nil
+		c.success(nil)
+		return true
+	}
+
+	if c.failed {
+		c.write(Structure{Signature: msgIgnored})
+		return true
+	}
+
+	switch msg.Signature {
+	case msgInit:
+		metadata := map[string]interface{}{"server": ServerAgent}
+		if c.version >= 3 {
+			metadata["connection_id"] = fmt.Sprintf("bolt-%d", c.server.Connections())
+		}
+		c.success(metadata)
+	case msgBegin, msgRollback:
+		c.success(nil)
+	case msgCommit:
+		c.success(map[string]interface{}{"bookmark": "bolttest:1"})
+	case msgRun:
+		return c.run(msg)
+	case msgPullAll, msgDiscardAll:
+		c.pull(msg.Signature == msgPullAll)
+	default:
+		c.failure(CodeUnexpectedQuery, fmt.Sprintf("bolttest: unsupported message 0x%X", msg.Signature))
+	}
+
+	return true
+}
+
+func (c *conn) run(msg Structure) bool {
+	if len(msg.Fields) < 2 {
+		c.failure(CodeUnexpectedQuery, "bolttest: invalid RUN message")
+		return true
+	}
+
+	statement, _ := msg.Fields[0].(string)
+	params, _ := msg.Fields[1].(map[string]interface{})
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	// Bolt v1 and v2 run transactions using plain statements
+	switch strings.ToUpper(statement) {
+	case "BEGIN", "COMMIT", "ROLLBACK":
+		c.result = &Expectation{}
+		if strings.ToUpper(statement) == "COMMIT" {
+			c.result.summary = map[string]interface{}{"bookmark": "bolttest:1"}
+		}
+		c.success(map[string]interface{}{"fields": []interface{}{}})
+		return true
+	}
+
+	e := c.server.match(statement, params)
+	if e == nil {
+		c.failure(CodeUnexpectedQuery, fmt.Sprintf("bolttest: unexpected query: %s", normalizeStatement(statement)))
+		return true
+	}
+
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+
+	if e.close {
+		return false
+	}
+
+	if e.code != "" {
+		c.failure(e.code, e.message)
+		return true
+	}
+
+	fields := make([]interface{}, len(e.fields))
+	for i, f := range e.fields {
+		fields[i] = f
+	}
+
+	c.result = e
+	metadata := map[string]interface{}{"fields": fields}
+	if c.version >= 3 {
+		metadata["t_first"] = int64(0)
+	} else {
+		metadata["result_available_after"] = int64(0)
+	}
+	c.success(metadata)
+
+	return true
+}
+
+func (c *conn) pull(records bool) {
+	e := c.result
+	c.result = nil
+	if e == nil {
+		c.failure(CodeUnexpectedQuery, "bolttest: no result to pull")
+		return
+	}
+
+	if records {
+		for _, r := range e.records {
+			c.write(Structure{Signature: msgRecord, Fields: []interface{}{r}})
+		}
+	}
+
+	metadata := map[string]interface{}{"type": "r"}
+	if c.version >= 3 {
+		metadata["t_last"] = int64(0)
+	} else {
+		metadata["result_consumed_after"] = int64(0)
+	}
+	for k, v := range e.summary {
+		metadata[k] = v
+	}
+
+	c.success(metadata)
+}
+
+func (c *conn) success(metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	c.write(Structure{Signature: msgSuccess, Fields: []interface{}{metadata}})
+}
+
+func (c *conn) failure(code string, message string) {
+	c.failed = true
+	c.result = nil
+	c.write(Structure{Signature: msgFailure, Fields: []interface{}{map[string]interface{}{"code": code, "message": message}}})
+}
+
+// read reads a chunked message
+func (c *conn) read() (Structure, error) {
+	var data []byte
+	for {
+		var size uint16
+		if err := binary.Read(c.rw, binary.BigEndian, &size); err != nil {
+			return Structure{}, err
+		}
+
+		if size == 0 {
+			// NOOP chunks (keep alive) may be sent between messages
+			if len(data) == 0 {
+				continue
+			}
+			break
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(c.rw, chunk); err != nil {
+			return Structure{}, err
+		}
+		data = append(data, chunk...)
+	}
+
+	v, err := (&unpacker{r: bytes.NewReader(data)}).unpack()
+	if err != nil {
+		return Structure{}, err
+	}
+
+	msg, ok := v.(Structure)
+	if !ok {
+		return Structure{}, fmt.Errorf("invalid message: %v", v)
+	}
+
+	return msg, nil
+}
+
+// write writes a chunked message. Errors are detected when flushing.
+func (c *conn) write(msg Structure) {
+	p := &packer{}
+	if err := p.pack(msg); err != nil {
+		p = &packer{}
+		p.pack(Structure{Signature: msgFailure, Fields: []interface{}{map[string]interface{}{
+			"code":    CodeUnexpectedQuery,
+			"message": fmt.Sprintf("bolttest: cannot pack response: %v", err),
+		}}})
+	}
+
+	data := p.buf.Bytes()
+	for len(data) > 0 {
+		n := len(data)
+		if n > 0xFFFF {
+			n = 0xFFFF
+		}
+
+		binary.Write(c.rw, binary.BigEndian, uint16(n))
+		c.rw.Write(data[:n])
+		data = data[n:]
+	}
+	c.rw.Write([]byte{0, 0})
+}
+
+func normalizeStatement(statement string) string {
+	return strings.Join(strings.Fields(statement), " ")
+}
@@ -0,0 +1,220 @@
+package bolttest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// client is a minimal Bolt client, sending messages the way the driver does
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, s *Server, versions ...uint32) (*client, uint32) {
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshake := append([]byte{}, handshakeMagic...)
+	for i := 0; i < 4; i++ {
+		v := uint32(0)
+		if i < len(versions) {
+			v = versions[i]
+		}
+		handshake = append(handshake, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(handshake[4+i*4:], v)
+	}
+	conn.Write(handshake)
+
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	var version uint32
+	if err := binary.Read(c.r, binary.BigEndian, &version); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, version
+}
+
+func (c *client) send(signature byte, fields ...interface{}) {
+	p := &packer{}
+	if err := p.pack(Structure{Signature: signature, Fields: fields}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	// Split in small chunks to exercise reassembly
+	data := p.buf.Bytes()
+	buf := &bytes.Buffer{}
+	for len(data) > 0 {
+		n := len(data)
+		if n > 7 {
+			n = 7
+		}
+		binary.Write(buf, binary.BigEndian, uint16(n))
+		buf.Write(data[:n])
+		data = data[n:]
+	}
+	buf.Write([]byte{0, 0})
+
+	c.conn.Write(buf.Bytes())
+}
+
+func (c *client) receive() Structure {
+	data := []byte{}
+	for {
+		var size uint16
+		if err := binary.Read(c.r, binary.BigEndian, &size); err != nil {
+			c.t.Fatal(err)
+		}
+		if size == 0 {
+			break
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(c.r, chunk); err != nil {
+			c.t.Fatal(err)
+		}
+		data = append(data, chunk...)
+	}
+
+	v, err := (&unpacker{r: bytes.NewReader(data)}).unpack()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return v.(Structure)
+}
+
+func (c *client) metadata(msg Structure) map[string]interface{} {
+	return msg.Fields[0].(map[string]interface{})
+}
+
+func TestPackStream(t *testing.T) {
+	values := []interface{}{
+		nil, true, false,
+		int64(0), int64(-16), int64(127), int64(-17), int64(128), int64(-129), int64(40000), int64(-3000000000),
+		1.5, "", "Keanu Reeves", string(make([]byte, 300)), []byte{1, 2, 3},
+		[]interface{}{int64(1), "two", []interface{}{}},
+		map[string]interface{}{"roles": []interface{}{"Neo"}, "born": int64(1964)},
+		Structure{Signature: 'N', Fields: []interface{}{int64(1), []interface{}{"Person"}, map[string]interface{}{}}},
+	}
+
+	for _, v := range values {
+		got, err := normalize(v)
+		assert.Nil(t, err)
+		assert.Equal(t, v, got)
+	}
+
+	got, err := normalize(map[string]interface{}{"i": 3, "u": uint8(4), "s": []string{"a"}, "f": float32(0.5)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"i": int64(3), "u": int64(4), "s": []interface{}{"a"}, "f": 0.5}, got)
+
+	got, err = normalize(Node{ID: 7, Labels: []string{"Movie"}, Props: map[string]interface{}{"title": "The Matrix"}})
+	assert.Nil(t, err)
+	assert.Equal(t, Structure{Signature: 'N', Fields: []interface{}{int64(7), []interface{}{"Movie"}, map[string]interface{}{"title": "The Matrix"}}}, got)
+}
+
+func TestServerV3(t *testing.T) {
+	s := NewServer(t)
+	s.Expect("match (m:Movie)\n  where m.uuid = $uuid\n  return m.title", map[string]interface{}{"uuid": "1"}).
+		Fields("m.title").
+		Record("The Matrix").
+		Record("The Matrix Reloaded").
+		Summary(map[string]interface{}{"plan": map[string]interface{}{"operatorType": "ProduceResults"}})
+
+	c, version := dial(t, s, 3, 2, 1, 0)
+	assert.Equal(t, uint32(3), version)
+
+	c.send(msgInit, map[string]interface{}{"user_agent": "test", "scheme": "none"})
+	hello := c.receive()
+	assert.Equal(t, byte(msgSuccess), hello.Signature)
+	assert.Equal(t, ServerAgent, c.metadata(hello)["server"])
+
+	c.send(msgRun, "match (m:Movie) where m.uuid = $uuid return m.title", map[string]interface{}{"uuid": "1"}, map[string]interface{}{})
+	c.send(msgPullAll)
+
+	run := c.receive()
+	assert.Equal(t, byte(msgSuccess), run.Signature)
+	assert.Equal(t, []interface{}{"m.title"}, c.metadata(run)["fields"])
+
+	assert.Equal(t, Structure{Signature: msgRecord, Fields: []interface{}{[]interface{}{"The Matrix"}}}, c.receive())
+	assert.Equal(t, Structure{Signature: msgRecord, Fields: []interface{}{[]interface{}{"The Matrix Reloaded"}}}, c.receive())
+
+	summary := c.receive()
+	assert.Equal(t, byte(msgSuccess), summary.Signature)
+	assert.Equal(t, map[string]interface{}{"operatorType": "ProduceResults"}, c.metadata(summary)["plan"])
+
+	c.send(msgGoodbye)
+	assert.Equal(t, []string{"match (m:Movie) where m.uuid = $uuid return m.title"}, s.Queries())
+}
+
+func TestServerFailures(t *testing.T) {
+	s, err := Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Expect("RETURN 1", nil).Fail("Neo.TransientError.General.DatabaseUnavailable", "unavailable")
+	s.Expect("RETURN 1", nil).Fields("1").Record(1)
+	s.Expect("RETURN 2", nil).Disconnect()
+	s.Expect("RETURN 3", nil).Times(2)
+
+	c, version := dial(t, s, 2, 1)
+	assert.Equal(t, uint32(2), version)
+	c.send(msgInit, "test", map[string]interface{}{"scheme": "none"})
+	assert.Equal(t, byte(msgSuccess), c.receive().Signature)
+
+	// Failures are followed by IGNORED until the failure is acknowledged
+	c.send(msgRun, "RETURN 1", map[string]interface{}{})
+	c.send(msgPullAll)
+	failure := c.receive()
+	assert.Equal(t, byte(msgFailure), failure.Signature)
+	assert.Equal(t, "Neo.TransientError.General.DatabaseUnavailable", c.metadata(failure)["code"])
+	assert.Equal(t, byte(msgIgnored), c.receive().Signature)
+
+	c.send(msgAckFailure)
+	assert.Equal(t, byte(msgSuccess), c.receive().Signature)
+
+	// A retry gets the next expectation
+	c.send(msgRun, "RETURN 1", map[string]interface{}{})
+	c.send(msgPullAll)
+	assert.Equal(t, byte(msgSuccess), c.receive().Signature)
+	assert.Equal(t, Structure{Signature: msgRecord, Fields: []interface{}{[]interface{}{int64(1)}}}, c.receive())
+	assert.Equal(t, byte(msgSuccess), c.receive().Signature)
+
+	// Unexpected queries fail and are reported
+	c.send(msgRun, "RETURN 4", map[string]interface{}{"x": 1})
+	assert.Equal(t, byte(msgFailure), c.receive().Signature)
+	c.send(msgReset)
+	assert.Equal(t, byte(msgSuccess), c.receive().Signature)
+	assert.Equal(t, []string{"RETURN 4 map[x:1]"}, s.Unexpected())
+
+	// Disconnections close the connection
+	c.send(msgRun, "RETURN 2", map[string]interface{}{})
+	_, err = c.r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+
+	assert.Len(t, s.Pending(), 1)
+	assert.Equal(t, 1, s.Connections())
+}
+
+func TestServerVersionMismatch(t *testing.T) {
+	s := NewServer(t)
+	s.SetVersion(2)
+
+	c, version := dial(t, s, 4)
+	assert.Equal(t, uint32(0), version)
+
+	_, err := c.r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
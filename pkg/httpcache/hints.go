@@ -0,0 +1,125 @@
+// Package httpcache lets HTTP caches (e.g. a CDN) store the responses of GraphQL GET
+// queries, with Cache-Control headers derived from @cacheControl schema hints and
+// ETags computed from response bodies
+package httpcache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// directive is the schema directive holding cache hints:
+//   directive @cacheControl(maxAge: Int!) on FIELD_DEFINITION | OBJECT
+const directive = "cacheControl"
+
+// Hints is a gqlgen extension computing the max age of successful queries, used by
+// Middleware to set the Cache-Control header of their responses
+type Hints struct {
+	schema *ast.Schema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = &Hints{}
+
+// ExtensionName returns the extension name
+func (h *Hints) ExtensionName() string {
+	return "CacheControl"
+}
+
+// Validate keeps the schema, where hints are found
+func (h *Hints) Validate(schema graphql.ExecutableSchema) error {
+	h.schema = schema.Schema()
+	return nil
+}
+
+// InterceptResponse records the max age of queries resolved without errors, in
+// requests going through Middleware
+func (h *Hints) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+
+	p, ok := ctx.Value(ctxKey{}).(*policy)
+	if !ok || !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+
+	rc := graphql.GetOperationContext(ctx)
+	if resp != nil && len(resp.Errors) == 0 && rc.Operation != nil && rc.Operation.Operation == ast.Query {
+		p.maxAge = MaxAge(h.schema, rc.Operation.SelectionSet)
+		p.cacheable = true
+	}
+
+	return resp
+}
+
+// MaxAge returns the max age, in seconds, of a selection set: the lowest maxAge hint
+// of the root fields and of the fields returning objects, taken from the field
+// definition or else from the type of the field. Fields without hint have a max age of 0,
+// scalar fields inherit the max age of their parent.
+func MaxAge(schema *ast.Schema, set ast.SelectionSet) int {
+	return maxAge(schema, set, true, -1)
+}
+
+// maxAge returns the lowest of max and the max age of set, -1 meaning no limit
+func maxAge(schema *ast.Schema, set ast.SelectionSet, root bool, max int) int {
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			if s.Definition == nil || s.Name == "__typename" {
+				continue
+			}
+
+			t := schema.Types[s.Definition.Type.Name()]
+			if root || (t != nil && t.Kind != ast.Scalar && t.Kind != ast.Enum) {
+				hint, ok := hintOf(s.Definition.Directives)
+				if !ok && t != nil {
+					hint, ok = hintOf(t.Directives)
+				}
+				if !ok {
+					return 0
+				}
+				if max < 0 || hint < max {
+					max = hint
+				}
+			}
+
+			max = maxAge(schema, s.SelectionSet, false, max)
+		case *ast.InlineFragment:
+			max = maxAge(schema, s.SelectionSet, root, max)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				max = maxAge(schema, s.Definition.SelectionSet, root, max)
+			}
+		}
+
+		if max == 0 {
+			return 0
+		}
+	}
+
+	return max
+}
+
+// hintOf returns the maxAge argument of a @cacheControl directive in directives
+func hintOf(directives ast.DirectiveList) (int, bool) {
+	d := directives.ForName(directive)
+	if d == nil {
+		return 0, false
+	}
+
+	arg := d.Arguments.ForName("maxAge")
+	if arg == nil || arg.Value == nil {
+		return 0, false
+	}
+
+	maxAge, err := strconv.Atoi(arg.Value.Raw)
+	if err != nil || maxAge < 0 {
+		return 0, false
+	}
+
+	return maxAge, true
+}
@@ -0,0 +1,63 @@
+package httpcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	directive @cacheControl(maxAge: Int!) on FIELD_DEFINITION | OBJECT
+	type Query {
+		movie: Movie
+		movies: [Movie!]! @cacheControl(maxAge: 60)
+		me: User
+		version: String @cacheControl(maxAge: 30)
+		uptime: Int
+	}
+	type Movie @cacheControl(maxAge: 300) { title: String cast: [Person!]! reviewers: [Person!] @cacheControl(maxAge: 10) }
+	type Person @cacheControl(maxAge: 120) { name: String }
+	type User { subject: String }
+`})
+
+func TestMaxAge(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		maxAge int
+	}{
+		{name: "type hint", query: `{ movie { title } }`, maxAge: 300},
+		{name: "field hint", query: `{ movies { title } }`, maxAge: 60},
+		{name: "nested type hint", query: `{ movie { cast { name } } }`, maxAge: 120},
+		{name: "nested field hint", query: `{ movie { reviewers { name } } }`, maxAge: 10},
+		{name: "scalar root field hint", query: `{ version movie { title } }`, maxAge: 30},
+		{name: "scalar root field without hint", query: `{ uptime movie { title } }`, maxAge: 0},
+		{name: "object without hint", query: `{ me { subject } movie { title } }`, maxAge: 0},
+		{name: "fragments", query: `{ movie { ...cast } } fragment cast on Movie { ... on Movie { cast { name } } }`, maxAge: 120},
+		{name: "typename", query: `{ __typename movie { __typename title } }`, maxAge: 300},
+		{name: "introspection", query: `{ __schema { types { name } } }`, maxAge: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := gqlparser.LoadQuery(schema, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.maxAge, MaxAge(schema, doc.Operations[0].SelectionSet))
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	etag := `"0123"`
+
+	assert.True(t, matches(`"0123"`, etag))
+	assert.True(t, matches(`"abcd", W/"0123"`, etag))
+	assert.True(t, matches(`*`, etag))
+	assert.False(t, matches(`"abcd"`, etag))
+	assert.False(t, matches(``, etag))
+}
@@ -0,0 +1,106 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+)
+
+type ctxKey struct{}
+
+// policy is the caching policy of a response, set by the Hints extension
+type policy struct {
+	cacheable bool
+	maxAge    int
+}
+
+// Middleware sets the Cache-Control and ETag headers of successful responses to GET
+// requests, answering 304 Not Modified when the ETag matches If-None-Match. Responses
+// to authenticated requests are private. It must run after authentication.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := &policy{}
+		buf := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buf, r.WithContext(context.WithValue(r.Context(), ctxKey{}, p)))
+
+		if buf.status != http.StatusOK {
+			buf.flush()
+			return
+		}
+
+		w.Header().Add("Vary", "Authorization, "+auth.APIKeyHeader)
+		w.Header().Set("Cache-Control", cacheControl(p, auth.FromContext(r.Context()) != nil))
+
+		etag := etag(buf.body.Bytes())
+		w.Header().Set("ETag", etag)
+		if matches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		buf.flush()
+	})
+}
+
+// cacheControl returns the Cache-Control header value of a response
+func cacheControl(p *policy, private bool) string {
+	if !p.cacheable || p.maxAge <= 0 {
+		return "no-store"
+	}
+	if private {
+		return fmt.Sprintf("private, max-age=%d", p.maxAge)
+	}
+
+	return fmt.Sprintf("public, max-age=%d", p.maxAge)
+}
+
+// etag returns a strong ETag computed from a response body
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matches tells whether an If-None-Match header value lists etag, or is "*".
+// Weak comparison is used, as RFC 7232 requires for If-None-Match.
+func matches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferedWriter holds a response until its headers are set by the middleware
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// flush writes the response held by w
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -0,0 +1,125 @@
+// Package limits provides gqlgen extensions bounding the work done by GraphQL operations
+package limits
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// DepthLimit is a gqlgen extension that rejects operations selecting fields nested
+// deeper than Max levels. Introspection queries (the __schema and __type fields) nest
+// deeply by design, so they can be limited separately with MaxIntrospection, which
+// defaults to Max.
+type DepthLimit struct {
+	Max              int
+	MaxIntrospection int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = DepthLimit{}
+
+// ExtensionName returns the extension name
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+// Validate is a no-op, the extension works with any schema
+func (DepthLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationContext returns an error, reporting the operation depth, when it exceeds the limit
+func (d DepthLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		return nil
+	}
+
+	maxIntrospection := d.MaxIntrospection
+	if maxIntrospection == 0 {
+		maxIntrospection = d.Max
+	}
+
+	depth, introspectionDepth := 0, 0
+	for _, f := range rootFields(op.SelectionSet) {
+		fd := 1 + Depth(f.SelectionSet)
+		if strings.HasPrefix(f.Name, "__") {
+			if fd > introspectionDepth {
+				introspectionDepth = fd
+			}
+		} else if fd > depth {
+			depth = fd
+		}
+	}
+
+	if depth > d.Max {
+		return depthError("operation", depth, d.Max)
+	}
+	if introspectionDepth > maxIntrospection {
+		return depthError("introspection", introspectionDepth, maxIntrospection)
+	}
+
+	return nil
+}
+
+func depthError(kind string, depth, max int) *gqlerror.Error {
+	err := gqlerror.Errorf("%s has depth %d, which exceeds the limit of %d", kind, depth, max)
+	errcode.Set(err, errDepthLimit)
+	return err
+}
+
+// rootFields returns the fields of a selection set, those of its fragments included
+func rootFields(set ast.SelectionSet) []*ast.Field {
+	var fields []*ast.Field
+
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			fields = append(fields, s)
+		case *ast.InlineFragment:
+			fields = append(fields, rootFields(s.SelectionSet)...)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				fields = append(fields, rootFields(s.Definition.SelectionSet)...)
+			}
+		}
+	}
+
+	return fields
+}
+
+// Depth returns the number of nested field levels of a selection set, introspection
+// fields included. Fragments do not add levels, and their cycles are rejected by
+// validation before this runs.
+func Depth(set ast.SelectionSet) int {
+	max := 0
+
+	for _, s := range set {
+		var d int
+		switch s := s.(type) {
+		case *ast.Field:
+			d = 1 + Depth(s.SelectionSet)
+		case *ast.InlineFragment:
+			d = Depth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				d = Depth(s.Definition.SelectionSet)
+			}
+		}
+
+		if d > max {
+			max = d
+		}
+	}
+
+	return max
+}
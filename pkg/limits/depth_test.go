@@ -0,0 +1,91 @@
+package limits
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	type Query { movie: Movie }
+	type Movie { title: String cast: [Person!]! }
+	type Person { name: String participated: [Movie!]! }
+`})
+
+func TestDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		depth int
+	}{
+		{name: "flat", query: `{ movie { title } }`, depth: 2},
+		{name: "nested", query: `{ movie { title cast { name participated { title } } } }`, depth: 4},
+		{name: "fragment", query: `{ movie { ...castNames } } fragment castNames on Movie { cast { name } }`, depth: 3},
+		{name: "inline fragment", query: `{ movie { ... on Movie { cast { name } } } }`, depth: 3},
+		{name: "introspection", query: `{ __schema { types { fields { type { name } } } } movie { title } }`, depth: 5},
+		{name: "typename", query: `{ movie { __typename } }`, depth: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := gqlparser.LoadQuery(schema, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.depth, Depth(doc.Operations[0].SelectionSet))
+		})
+	}
+}
+
+// playgroundQuery has the shape of the introspection query sent by the playground
+const playgroundQuery = `
+	query IntrospectionQuery { __schema { types { ...FullType } } }
+	fragment FullType on __Type { fields { args { ...InputValue } type { ...TypeRef } } }
+	fragment InputValue on __InputValue { type { ...TypeRef } }
+	fragment TypeRef on __Type {
+		kind name ofType { kind name ofType { kind name ofType { kind name ofType {
+			kind name ofType { kind name ofType { kind name ofType { kind name } } }
+		} } } }
+	}
+`
+
+func TestDepthLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit DepthLimit
+		query string
+		err   string
+	}{
+		{name: "within limit", limit: DepthLimit{Max: 4}, query: `{ movie { cast { participated { title } } } }`},
+		{name: "too deep", limit: DepthLimit{Max: 3}, query: `{ movie { cast { participated { title } } } }`, err: "operation has depth 4, which exceeds the limit of 3"},
+		{name: "introspection within limit", limit: DepthLimit{Max: 3, MaxIntrospection: 15}, query: playgroundQuery},
+		{name: "introspection too deep", limit: DepthLimit{Max: 3, MaxIntrospection: 10}, query: playgroundQuery, err: "introspection has depth 13, which exceeds the limit of 10"},
+		{name: "introspection limited by max", limit: DepthLimit{Max: 3}, query: `{ __type(name: "Movie") { fields { type { name } } } }`, err: "introspection has depth 4, which exceeds the limit of 3"},
+		{name: "introspection in fragment", limit: DepthLimit{Max: 3, MaxIntrospection: 3}, query: `{ ...schema } fragment schema on Query { __schema { types { fields { name } } } }`, err: "introspection has depth 4, which exceeds the limit of 3"},
+		{name: "typename", limit: DepthLimit{Max: 2}, query: `{ movie { __typename } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := gqlparser.LoadQuery(schema, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gqlErr := tt.limit.MutateOperationContext(context.Background(), &graphql.OperationContext{Doc: doc})
+			if tt.err == "" {
+				assert.Nil(t, gqlErr)
+				return
+			}
+			if assert.NotNil(t, gqlErr) {
+				assert.Equal(t, tt.err, gqlErr.Message)
+				assert.Equal(t, errDepthLimit, gqlErr.Extensions["code"])
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header used to receive and propagate request ids
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of request ids received from clients
+const maxRequestIDLength = 128
+
+const (
+	// FieldRequestID is the log field holding the request id
+	FieldRequestID = "request_id"
+	// FieldOperation is the log field holding the GraphQL operation name
+	FieldOperation = "operation"
+	// FieldUser is the log field holding the authenticated user
+	FieldUser = "user"
+)
+
+type ctxKey struct{}
+
+// Entry is a logger bound to a set of fields (e.g. those of a request context) and,
+// optionally, to its own sink. It supports the same arguments as the package level
+// functions. Entries can be injected into application components, a nil *Entry
+// logs to the default sink.
+type Entry struct {
+	fields LogFields
+	sink   Sink
+}
+
+// New returns an entry writing to s, or to the default sink if s is nil
+func New(s Sink) *Entry {
+	return &Entry{fields: LogFields{}, sink: s}
+}
+
+// Default returns an entry writing to the default sink
+func Default() *Entry {
+	return New(nil)
+}
+
+// NewContext returns a copy of ctx carrying fields, on top of any fields already in ctx
+func NewContext(ctx context.Context, fields LogFields) context.Context {
+	merged := LogFields{}
+	for k, v := range fieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, ctxKey{}, merged)
+}
+
+// FromContext returns a logger carrying request id, operation name and user found in ctx
+func FromContext(ctx context.Context) *Entry {
+	return &Entry{fields: fieldsFromContext(ctx)}
+}
+
+// WithContext returns an entry writing to the same sink as e, carrying the fields
+// of e and those found in ctx
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	if e == nil {
+		return FromContext(ctx)
+	}
+
+	return &Entry{fields: fieldsFromContext(NewContext(ctx, e.fields)), sink: e.sink}
+}
+
+// RequestID returns the request id stored in ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := fieldsFromContext(ctx)[FieldRequestID].(string)
+	return id
+}
+
+func fieldsFromContext(ctx context.Context) LogFields {
+	if ctx == nil {
+		return LogFields{}
+	}
+
+	fields, ok := ctx.Value(ctxKey{}).(LogFields)
+	if !ok {
+		return LogFields{}
+	}
+
+	return fields
+}
+
+// backend returns the sink of the entry, nil meaning the default sink
+func (e *Entry) backend() Sink {
+	if e == nil {
+		return nil
+	}
+
+	return e.sink
+}
+
+// with prepends the entry fields, so explicit fields passed by the caller take precedence
+func (e *Entry) with(args []interface{}) []interface{} {
+	if e == nil {
+		return args
+	}
+
+	return append([]interface{}{e.fields}, args...)
+}
+
+// Debug logs a message with "debug" level
+func (e *Entry) Debug(args ...interface{}) {
+	write(e.backend(), logrus.DebugLevel, e.with(args))
+}
+
+// Info logs a message with "info" level
+func (e *Entry) Info(args ...interface{}) {
+	write(e.backend(), logrus.InfoLevel, e.with(args))
+}
+
+// Warning logs a message with "warning" level
+func (e *Entry) Warning(args ...interface{}) {
+	write(e.backend(), logrus.WarnLevel, e.with(args))
+}
+
+// Error logs a message with "error" level
+func (e *Entry) Error(args ...interface{}) {
+	write(e.backend(), logrus.ErrorLevel, e.with(args))
+}
+
+// RequestIDMiddleware reads the request id from the X-Request-ID header (generating
+// a new one when missing or invalid), echoes it back in the response and stores it in
+// the request context
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := NewContext(r.Context(), LogFields{FieldRequestID: id})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID tells whether a request id received from a client can be used as is:
+// it ends up in logs, response headers and errors, so it must be short and made of
+// letters, digits, '.', '_' and '-'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Tracer is a gqlgen extension that adds the operation name to the log context
+type Tracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = Tracer{}
+
+// ExtensionName returns the extension name
+func (Tracer) ExtensionName() string {
+	return "Logger"
+}
+
+// Validate is a no-op, the tracer works with any schema
+func (Tracer) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation stores the operation name in the log context
+func (Tracer) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	name := graphql.GetOperationContext(ctx).OperationName
+	if name == "" {
+		name = "anonymous"
+	}
+
+	return next(NewContext(ctx, LogFields{FieldOperation: name}))
+}
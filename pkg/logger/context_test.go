@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var id string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+	}))
+
+	serve := func(header string) *httptest.ResponseRecorder {
+		id = ""
+		r := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		if header != "" {
+			r.Header.Set(RequestIDHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// Valid ids are kept
+	for _, valid := range []string{"abc", "2c7d9c0e-6e4f-5b1a.8d3e_0f9a", strings.Repeat("a", maxRequestIDLength)} {
+		rec := serve(valid)
+		assert.Equal(t, valid, id)
+		assert.Equal(t, valid, rec.Header().Get(RequestIDHeader))
+	}
+
+	// Others are replaced by a generated one
+	for _, invalid := range []string{"", "abc\ninjected=true", "a b", `"quoted"`, "é", strings.Repeat("a", maxRequestIDLength+1)} {
+		rec := serve(invalid)
+		assert.Len(t, id, 32, invalid)
+		assert.NotEqual(t, invalid, id)
+		assert.Equal(t, id, rec.Header().Get(RequestIDHeader))
+	}
+}
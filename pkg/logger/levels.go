@@ -0,0 +1,327 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// levelConfig holds the default level and per-package overrides.
+// It can be changed at runtime, see SetLevel and SetPackageLevel.
+type levelConfig struct {
+	mu       sync.RWMutex
+	level    logrus.Level
+	packages map[string]logrus.Level
+}
+
+var levels = &levelConfig{
+	level:    logrus.DebugLevel,
+	packages: map[string]logrus.Level{},
+}
+
+// callerPackages caches the package of each calling function
+var callerPackages sync.Map
+
+// ParseLevel parses a level name (case insensitive). Besides the LogLevel
+// values it accepts "warn" and "err" as aliases.
+func ParseLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case string(LevelDebug):
+		return LevelDebug, nil
+	case string(LevelInfo):
+		return LevelInfo, nil
+	case string(LevelWarning), "warn":
+		return LevelWarning, nil
+	case string(LevelError), "err":
+		return LevelError, nil
+	case string(LevelFatal):
+		return LevelFatal, nil
+	default:
+		return "", fmt.Errorf("Invalid log level: %q", level)
+	}
+}
+
+// toLogrusLevel maps a level name to a logrus level, defaulting to debug
+func toLogrusLevel(level string) logrus.Level {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return logrus.DebugLevel
+	}
+
+	return l.logrus()
+}
+
+func (l LogLevel) logrus() logrus.Level {
+	switch l {
+	case LevelInfo:
+		return logrus.InfoLevel
+	case LevelWarning:
+		return logrus.WarnLevel
+	case LevelError:
+		return logrus.ErrorLevel
+	case LevelFatal:
+		return logrus.FatalLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
+
+func fromLogrusLevel(l logrus.Level) LogLevel {
+	switch l {
+	case logrus.InfoLevel:
+		return LevelInfo
+	case logrus.WarnLevel:
+		return LevelWarning
+	case logrus.ErrorLevel:
+		return LevelError
+	case logrus.FatalLevel, logrus.PanicLevel:
+		return LevelFatal
+	default:
+		return LevelDebug
+	}
+}
+
+// SetLevel changes the default level at runtime
+func SetLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	levels.mu.Lock()
+	levels.level = l.logrus()
+	levels.mu.Unlock()
+
+	syncLogrusLevel()
+	return nil
+}
+
+// SetPackageLevel changes the level of a single package at runtime. Packages are
+// identified by their import path or its last element (e.g. "repository").
+// An empty level removes the override.
+func SetPackageLevel(pkg string, level string) error {
+	if level == "" {
+		levels.mu.Lock()
+		delete(levels.packages, pkg)
+		levels.mu.Unlock()
+
+		syncLogrusLevel()
+		return nil
+	}
+
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	levels.mu.Lock()
+	levels.packages[pkg] = l.logrus()
+	levels.mu.Unlock()
+
+	syncLogrusLevel()
+	return nil
+}
+
+// setLevels changes the default level (unless empty) and the given package
+// levels in a single update, so entries are never filtered by half of it.
+// Nothing is changed if any level is invalid.
+func setLevels(level LogLevel, packages map[string]LogLevel) error {
+	def := logrus.Level(0)
+	if level != "" {
+		l, err := ParseLevel(string(level))
+		if err != nil {
+			return err
+		}
+		def = l.logrus()
+	}
+
+	overrides := map[string]*logrus.Level{}
+	for pkg, level := range packages {
+		if level == "" {
+			overrides[pkg] = nil
+			continue
+		}
+
+		l, err := ParseLevel(string(level))
+		if err != nil {
+			return err
+		}
+		ll := l.logrus()
+		overrides[pkg] = &ll
+	}
+
+	levels.mu.Lock()
+	if level != "" {
+		levels.level = def
+	}
+	for pkg, l := range overrides {
+		if l == nil {
+			delete(levels.packages, pkg)
+		} else {
+			levels.packages[pkg] = *l
+		}
+	}
+	levels.mu.Unlock()
+
+	syncLogrusLevel()
+	return nil
+}
+
+// Levels returns the default level and the per-package overrides
+func Levels() (LogLevel, map[string]LogLevel) {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+
+	packages := map[string]LogLevel{}
+	for pkg, l := range levels.packages {
+		packages[pkg] = fromLogrusLevel(l)
+	}
+
+	return fromLogrusLevel(levels.level), packages
+}
+
+// ReloadLevels sets default and per-package levels from LOGGER_LEVEL and
+// LOGGER_PACKAGE_LEVELS (e.g. "repository=debug,service=warning")
+func ReloadLevels() error {
+	def, err := ParseLevel(viper.GetString("LOGGER_LEVEL"))
+	if err != nil {
+		def = LevelDebug
+	}
+
+	packages := map[string]logrus.Level{}
+	for _, pair := range strings.Split(viper.GetString("LOGGER_PACKAGE_LEVELS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid package level: %q", pair)
+		}
+
+		l, err := ParseLevel(parts[1])
+		if err != nil {
+			return err
+		}
+		packages[strings.TrimSpace(parts[0])] = l.logrus()
+	}
+
+	levels.mu.Lock()
+	levels.level = def.logrus()
+	levels.packages = packages
+	levels.mu.Unlock()
+
+	syncLogrusLevel()
+	return nil
+}
+
+// syncLogrusLevel sets the logrus level to the most verbose configured level,
+// so entries logged through NewLogger are filtered consistently
+func syncLogrusLevel() {
+	if Logger == nil {
+		return
+	}
+
+	levels.mu.RLock()
+	max := levels.level
+	for _, l := range levels.packages {
+		if l > max {
+			max = l
+		}
+	}
+	levels.mu.RUnlock()
+
+	Logger.SetLevel(max)
+}
+
+// enabled reports whether an entry of the given level, logged from the function
+// skip frames above the caller, should be emitted
+func (c *levelConfig) enabled(level logrus.Level, skip int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.packages) == 0 {
+		return level <= c.level
+	}
+
+	pkg := callerPackage(skip + 1)
+	for _, name := range []string{pkg, pkg[strings.LastIndex(pkg, "/")+1:]} {
+		if l, ok := c.packages[name]; ok {
+			return level <= l
+		}
+	}
+
+	return level <= c.level
+}
+
+// callerPackage returns the import path of the function skip frames above the caller
+func callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+
+	if pkg, ok := callerPackages.Load(pc); ok {
+		return pkg.(string)
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	// e.g. github.com/charlysan/goneo4jgql/internal/app/repository.(*Neo4jRepository).FindMovies
+	name := fn.Name()
+	slash := strings.LastIndex(name, "/")
+	pkg := name
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		pkg = name[:slash+1+dot]
+	}
+
+	callerPackages.Store(pc, pkg)
+	return pkg
+}
+
+// levelsPayload is used to read and change levels through LevelHandler
+type levelsPayload struct {
+	Level    LogLevel            `json:"level,omitempty"`
+	Packages map[string]LogLevel `json:"packages,omitempty"`
+}
+
+// LevelHandler returns an HTTP handler to inspect (GET) and change (PUT) log levels
+// at runtime, e.g. {"level": "info", "packages": {"repository": "debug"}}.
+// Setting an empty level for a package removes its override.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			payload := levelsPayload{}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := setLevels(payload.Level, payload.Packages); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			Info("Log levels changed", LogFields{"level": payload.Level, "packages": payload.Packages})
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		level, packages := Levels()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levelsPayload{Level: level, Packages: packages})
+	})
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogger replaces Logger by one writing JSON entries to the returned buffer.
+// Logger, LOGGER_LEVEL and LOGGER_PACKAGE_LEVELS are restored when the test ends.
+func captureLogger(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+
+	previous := Logger
+	level := viper.GetString("LOGGER_LEVEL")
+	packageLevels := viper.GetString("LOGGER_PACKAGE_LEVELS")
+	t.Cleanup(func() {
+		Logger = previous
+		viper.Set("LOGGER_LEVEL", level)
+		viper.Set("LOGGER_PACKAGE_LEVELS", packageLevels)
+		assert.NoError(t, ReloadLevels())
+	})
+
+	Logger = logrus.New()
+	Logger.SetOutput(ioutil.Discard)
+	Logger.SetFormatter(discardFormatter{})
+	Logger.AddHook(&output{
+		writer:    buf,
+		formatter: &logrus.JSONFormatter{},
+		level:     logrus.TraceLevel,
+		out:       &logrus.Logger{Out: buf},
+	})
+
+	return buf
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, expected := range map[string]LogLevel{
+		"debug":   LevelDebug,
+		"INFO":    LevelInfo,
+		"WARN":    LevelWarning,
+		"warning": LevelWarning,
+		"Error":   LevelError,
+		"fatal":   LevelFatal,
+	} {
+		level, err := ParseLevel(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, level, input)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestLevelFiltering(t *testing.T) {
+	buf := captureLogger(t)
+	viper.Set("LOGGER_LEVEL", "WARN")
+	viper.Set("LOGGER_PACKAGE_LEVELS", "")
+	assert.NoError(t, ReloadLevels())
+
+	Info("info message")
+	Warning("warning message")
+
+	assert.NotContains(t, buf.String(), "info message")
+	assert.Contains(t, buf.String(), "warning message")
+	assert.Equal(t, logrus.WarnLevel, Logger.GetLevel())
+}
+
+func TestPackageLevel(t *testing.T) {
+	buf := captureLogger(t)
+	viper.Set("LOGGER_LEVEL", "error")
+	viper.Set("LOGGER_PACKAGE_LEVELS", "logger=debug")
+	assert.NoError(t, ReloadLevels())
+
+	Debug("debug message")
+	assert.Contains(t, buf.String(), "debug message")
+
+	assert.NoError(t, SetPackageLevel("logger", ""))
+	Debug("hidden message")
+	assert.NotContains(t, buf.String(), "hidden message")
+
+	level, packages := Levels()
+	assert.Equal(t, LevelError, level)
+	assert.Empty(t, packages)
+}
+
+func TestLevelHandler(t *testing.T) {
+	captureLogger(t)
+	viper.Set("LOGGER_LEVEL", "error")
+	viper.Set("LOGGER_PACKAGE_LEVELS", "repository=debug")
+	assert.NoError(t, ReloadLevels())
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		LevelHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body)))
+		return rec
+	}
+
+	// An invalid package level rejects the whole update
+	rec := put(`{"level": "info", "packages": {"service": "warning", "repository": "loud"}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	level, packages := Levels()
+	assert.Equal(t, LevelError, level)
+	assert.Equal(t, map[string]LogLevel{"repository": LevelDebug}, packages)
+
+	rec = put(`{"level": "info", "packages": {"service": "warning", "repository": ""}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level": "info", "packages": {"service": "warning"}}`, rec.Body.String())
+}
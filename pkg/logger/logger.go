@@ -10,17 +10,37 @@
 // Then the logger will only log entries with that severity or anything above it.
 //   e.g.  LOGGER_LEVEL=INFO --> Will log anything that is info or above
 //   (warn, error, fatal)
+//
+// Sensitive values (passwords, tokens, emails, ...) are redacted from messages,
+// fields and errors before being written, see LOGGER_REDACT_KEYS and LOGGER_REDACT_DETECTORS.
+//
+// Levels can be overridden per package using env var LOGGER_PACKAGE_LEVELS
+//   e.g.  LOGGER_PACKAGE_LEVELS=repository=debug,service=warning
+// and changed at runtime with SetLevel and SetPackageLevel (see LevelHandler).
+//
+// High volume entries can be sampled per level and message using LOGGER_SAMPLING,
+// and repeated stack traces are omitted within LOGGER_TRACE_DEDUP_INTERVAL.
+//
+// Entries are written by a Sink. The default one is the logrus logger configured
+// by InitializeLogger; SetSink, NewSlogSink and New allow using another backend
+// (see CaptureSink for tests).
+//
+// The default sink writes to the outputs listed in LOGGER_OUTPUT (comma separated):
+//    "stderr" (default)
+//    "stdout"
+//    any other value is used as a file path, rotated by size and time
+// Each output can set its own formatter and level, e.g. "/var/log/api.log?formatter=json&level=error".
 package logger
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
-	"strings"
 
 	"github.com/go-errors/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/spf13/viper"
-	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 )
 
 // LogLevel type used for specifying log level
@@ -45,42 +65,47 @@ const (
 	FormatConsole LogFormatter = "console"
 	// FormatLogstash formats logs output to logstash format (JSON)
 	FormatLogstash LogFormatter = "logstash"
+	// FormatJSON formats logs output to plain JSON
+	FormatJSON LogFormatter = "json"
 )
 
 // InitializeLogger initializes a new logger with format and log level
 func InitializeLogger() {
 	logger := logrus.New()
 
-	// TODO: add possibility to output to file in the future
-	logger.SetOutput(os.Stderr)
-
-	formatter := viper.GetString("LOGGER_FORMATTER")
-	switch strings.ToLower(formatter) {
-	case string(FormatConsole):
-		logger.SetFormatter(&prefixed.TextFormatter{})
-	case string(FormatLogstash):
-		logger.SetFormatter(&logrus.JSONFormatter{})
-	default:
-		logger.SetFormatter(&logrus.JSONFormatter{})
+	// Entries are formatted and written by each output
+	logger.SetOutput(ioutil.Discard)
+	logger.SetFormatter(discardFormatter{})
+
+	outputs, err := newOutputs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot initialize logger outputs, using stderr: %v\n", err)
+		outputs = []*output{{
+			writer:    os.Stderr,
+			formatter: newFormatter(viper.GetString("LOGGER_FORMATTER")),
+			level:     logrus.TraceLevel,
+			out:       &logrus.Logger{Out: os.Stderr},
+		}}
 	}
 
-	level := viper.GetString("LOGGER_LEVEL")
-	switch strings.ToLower(level) {
-	case string(LevelDebug):
-		logger.SetLevel(logrus.DebugLevel)
-	case string(LevelInfo):
-		logger.SetLevel(logrus.InfoLevel)
-	case string(LevelWarning):
-		logger.SetLevel(logrus.WarnLevel)
-	case string(LevelError):
-		logger.SetLevel(logrus.ErrorLevel)
-	case string(LevelFatal):
-		logger.SetLevel(logrus.FatalLevel)
-	default:
-		logger.SetLevel(logrus.DebugLevel)
+	// Redaction must happen before any output writes the entry
+	ReloadRedaction()
+	logger.AddHook(redactionHook{})
+
+	for _, o := range outputs {
+		logger.AddHook(o)
 	}
 
 	Logger = logger
+	setOutputs(outputs)
+
+	if err := ReloadLevels(); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set logger levels: %v\n", err)
+	}
+
+	if err := ReloadSampling(); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set logger sampling: %v\n", err)
+	}
 }
 
 // parseArguments parses arguments
@@ -120,24 +145,33 @@ func NewLogger() *logrus.Entry {
 	return Logger.WithFields(fields)
 }
 
-// Debug logs a debug message with "debug" level
-// It supports the following arguments:
-//  - string (for main log message)
-//  - error (for logging error trace)
-//  - logger.LogFields (for custom fields besides the log message - e.g. item_uuid)
-func Debug(args ...interface{}) {
-	level := strings.ToLower(viper.GetString("LOGGER_LEVEL"))
-	if level != string(LevelDebug) {
-		return
-	}
-
+// write logs an entry to s (or the default sink) if its level is enabled for the calling package.
+// It must be called directly by the exported logging functions, so the caller can be found.
+func write(s Sink, level logrus.Level, args []interface{}) {
 	// Initialize logger if it hasn't been initialized yet
 	if Logger == nil {
 		InitializeLogger()
 	}
 
+	if !levels.enabled(level, 2) {
+		return
+	}
+
 	msg, fields := parseArguments(args)
-	Logger.WithFields(fields).Debug(msg)
+	if !sample(level, msg, fields) {
+		return
+	}
+
+	currentSink(s).Log(fromLogrusLevel(level), RedactString(msg), Redact(fields))
+}
+
+// Debug logs a debug message with "debug" level
+// It supports the following arguments:
+//  - string (for main log message)
+//  - error (for logging error trace)
+//  - logger.LogFields (for custom fields besides the log message - e.g. item_uuid)
+func Debug(args ...interface{}) {
+	write(nil, logrus.DebugLevel, args)
 }
 
 // Info logs a debug message with "info" level
@@ -146,18 +180,7 @@ func Debug(args ...interface{}) {
 //  - error (for logging error trace)
 //  - logger.LogFields (for custom fields besides the log message - e.g. item_uuid)
 func Info(args ...interface{}) {
-	level := strings.ToLower(viper.GetString("LOGGER_LEVEL"))
-	if level != string(LevelInfo) && level != string(LevelDebug) {
-		return
-	}
-
-	// Initialize logger if it hasn't been initialized yet
-	if Logger == nil {
-		InitializeLogger()
-	}
-
-	msg, fields := parseArguments(args)
-	Logger.WithFields(fields).Info(msg)
+	write(nil, logrus.InfoLevel, args)
 }
 
 // Warning logs a debug message with "Warning" level
@@ -166,18 +189,7 @@ func Info(args ...interface{}) {
 //  - error (for logging error trace)
 //  - logger.LogFields (for custom fields besides the log message - e.g. item_uuid)
 func Warning(args ...interface{}) {
-	level := strings.ToLower(viper.GetString("LOGGER_LEVEL"))
-	if level == string(LevelError) || level == string(LevelFatal) {
-		return
-	}
-
-	// Initialize logger if it hasn't been initialized yet
-	if Logger == nil {
-		InitializeLogger()
-	}
-
-	msg, fields := parseArguments(args)
-	Logger.WithFields(fields).Warning(msg)
+	write(nil, logrus.WarnLevel, args)
 }
 
 // Error logs a debug message with "Error" level
@@ -186,18 +198,7 @@ func Warning(args ...interface{}) {
 //  - error (for logging error trace)
 //  - logger.LogFields (for custom fields besides the log message - e.g. item_uuid)
 func Error(args ...interface{}) {
-	level := strings.ToLower(viper.GetString("LOGGER_LEVEL"))
-	if level == string(LevelFatal) {
-		return
-	}
-
-	// Initialize logger if it hasn't been initialized yet
-	if Logger == nil {
-		InitializeLogger()
-	}
-
-	msg, fields := parseArguments(args)
-	Logger.WithFields(fields).Error(msg)
+	write(nil, logrus.ErrorLevel, args)
 }
 
 // Fatal logs a debug message with "Error" level
@@ -212,5 +213,6 @@ func Fatal(args ...interface{}) {
 	}
 
 	msg, fields := parseArguments(args)
-	Logger.WithFields(fields).Fatal(msg)
+	currentSink(nil).Log(LevelFatal, RedactString(msg), Redact(fields))
+	logrus.Exit(1)
 }
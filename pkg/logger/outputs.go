@@ -0,0 +1,276 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	prefixed "github.com/x-cray/logrus-prefixed-formatter"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// OutputStderr sends log entries to the standard error
+	OutputStderr = "stderr"
+	// OutputStdout sends log entries to the standard output
+	OutputStdout = "stdout"
+)
+
+// output is a logrus hook that writes entries of a given level (or above) to a
+// writer using its own formatter
+type output struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+	level     logrus.Level
+	// out is handed to formatters that need to inspect the output (e.g. for terminal detection)
+	out *logrus.Logger
+	// close releases the resources of the output (e.g. files and rotation tickers), if any
+	close func()
+}
+
+var (
+	outputsMu sync.Mutex
+	// activeOutputs are the outputs of Logger, closed when they are replaced
+	activeOutputs []*output
+)
+
+// setOutputs records the outputs of Logger, closing the previous ones
+func setOutputs(current []*output) {
+	outputsMu.Lock()
+	previous := activeOutputs
+	activeOutputs = current
+	outputsMu.Unlock()
+
+	for _, o := range previous {
+		if o.close != nil {
+			o.close()
+		}
+	}
+}
+
+// Levels returns the levels this output accepts
+func (o *output) Levels() []logrus.Level {
+	levels := []logrus.Level{}
+	for _, l := range logrus.AllLevels {
+		if l <= o.level {
+			levels = append(levels, l)
+		}
+	}
+
+	return levels
+}
+
+// Fire formats and writes an entry
+func (o *output) Fire(entry *logrus.Entry) error {
+	e := *entry
+	e.Logger = o.out
+
+	serialized, err := o.formatter.Format(&e)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.writer.Write(serialized)
+	return err
+}
+
+// newOutputs builds the outputs listed in LOGGER_OUTPUT (comma separated list of
+// "stderr", "stdout" or file paths). Each output can set its formatter and level with
+// options, e.g. "/var/log/api.log?formatter=json&level=error". Otherwise each kind of
+// output can override the global formatter and level using LOGGER_<STDERR|STDOUT|FILE>_FORMATTER
+// and LOGGER_<STDERR|STDOUT|FILE>_LEVEL.
+func newOutputs() ([]*output, error) {
+	config := viper.GetString("LOGGER_OUTPUT")
+	if config == "" {
+		config = OutputStderr
+	}
+
+	outputs := []*output{}
+	closeAll := func() {
+		for _, o := range outputs {
+			if o.close != nil {
+				o.close()
+			}
+		}
+	}
+
+	for _, spec := range strings.Split(config, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		name, options, err := parseOutput(spec)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		kind := strings.ToLower(name)
+		formatter := options.Get("formatter")
+		if formatter == "" {
+			formatter = viper.GetString(fmt.Sprintf("LOGGER_%s_FORMATTER", strings.ToUpper(outputKind(kind))))
+		}
+		if formatter == "" {
+			formatter = viper.GetString("LOGGER_FORMATTER")
+		}
+
+		// Outputs accept anything allowed by the logger levels, unless restricted further
+		level := logrus.TraceLevel
+		if l := options.Get("level"); l != "" {
+			parsed, err := ParseLevel(l)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			level = parsed.logrus()
+		} else if l := viper.GetString(fmt.Sprintf("LOGGER_%s_LEVEL", strings.ToUpper(outputKind(kind)))); l != "" {
+			level = toLogrusLevel(l)
+		}
+
+		o := &output{formatter: newFormatter(formatter), level: level}
+		switch kind {
+		case OutputStderr:
+			o.writer = os.Stderr
+		case OutputStdout:
+			o.writer = os.Stdout
+		default:
+			o.writer, o.close = newFileWriter(name)
+		}
+		o.out = &logrus.Logger{Out: o.writer}
+
+		outputs = append(outputs, o)
+	}
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("No logger output configured")
+	}
+
+	return outputs, nil
+}
+
+// parseOutput splits an output of LOGGER_OUTPUT into its name and its options
+func parseOutput(spec string) (string, url.Values, error) {
+	i := strings.Index(spec, "?")
+	if i < 0 {
+		return spec, url.Values{}, nil
+	}
+
+	options, err := url.ParseQuery(spec[i+1:])
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid logger output options %q: %v", spec, err)
+	}
+	for option := range options {
+		if option != "formatter" && option != "level" {
+			return "", nil, fmt.Errorf("Invalid logger output option %q in %q", option, spec)
+		}
+	}
+
+	return spec[:i], options, nil
+}
+
+// outputKind returns the kind of the output named name: stderr, stdout or file
+func outputKind(name string) string {
+	if name == OutputStderr || name == OutputStdout {
+		return name
+	}
+
+	return "file"
+}
+
+// newFileWriter returns a writer for path that rotates the file when it reaches
+// LOGGER_FILE_MAX_SIZE megabytes and every LOGGER_FILE_ROTATE_INTERVAL (if set),
+// keeping at most LOGGER_FILE_MAX_BACKUPS files for LOGGER_FILE_MAX_AGE days.
+// The returned function stops the rotation and closes the file.
+func newFileWriter(path string) (io.Writer, func()) {
+	w := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    viper.GetInt("LOGGER_FILE_MAX_SIZE"),
+		MaxAge:     viper.GetInt("LOGGER_FILE_MAX_AGE"),
+		MaxBackups: viper.GetInt("LOGGER_FILE_MAX_BACKUPS"),
+		Compress:   viper.GetBool("LOGGER_FILE_COMPRESS"),
+	}
+
+	interval := viper.GetDuration("LOGGER_FILE_ROTATE_INTERVAL")
+	if interval <= 0 {
+		return w, func() { w.Close() }
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := w.Rotate(); err != nil {
+					fmt.Fprintf(os.Stderr, "Cannot rotate log file %s: %v\n", path, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return w, func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-stopped
+			w.Close()
+		})
+	}
+}
+
+// logstashFormatter formats entries following logstash JSON event layout
+type logstashFormatter struct {
+	logrus.JSONFormatter
+}
+
+// Format renders a single log entry
+func (f *logstashFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	e := *entry
+	e.Data = logrus.Fields{"@version": "1"}
+	for k, v := range entry.Data {
+		e.Data[k] = v
+	}
+
+	return f.JSONFormatter.Format(&e)
+}
+
+// discardFormatter is used by the main logger, as entries are written by its outputs
+type discardFormatter struct{}
+
+// Format returns nothing
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+func newFormatter(formatter string) logrus.Formatter {
+	switch strings.ToLower(formatter) {
+	case string(FormatConsole):
+		return &prefixed.TextFormatter{}
+	case string(FormatLogstash):
+		return &logstashFormatter{
+			JSONFormatter: logrus.JSONFormatter{
+				FieldMap: logrus.FieldMap{
+					logrus.FieldKeyTime: "@timestamp",
+					logrus.FieldKeyMsg:  "message",
+				},
+			},
+		}
+	case string(FormatJSON):
+		return &logrus.JSONFormatter{}
+	default:
+		return &logrus.JSONFormatter{}
+	}
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// setOutputConfig sets a viper key for the duration of a test
+func setOutputConfig(t *testing.T, key string, value interface{}) {
+	previous := viper.Get(key)
+	t.Cleanup(func() { viper.Set(key, previous) })
+
+	viper.Set(key, value)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestOutputOptions(t *testing.T) {
+	dir := tempDir(t)
+	errors := filepath.Join(dir, "errors.log")
+	all := filepath.Join(dir, "all.log")
+	setOutputConfig(t, "LOGGER_OUTPUT", errors+"?formatter=logstash&level=error, "+all+"?formatter=json")
+	setOutputConfig(t, "LOGGER_FILE_LEVEL", "warning")
+
+	outputs, err := newOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	l.SetLevel(logrus.DebugLevel)
+	for _, o := range outputs {
+		l.AddHook(o)
+	}
+	l.Warning("Slow query")
+	l.Error("Cannot find movie")
+
+	for _, o := range outputs {
+		o.close()
+	}
+
+	// Options take precedence over the settings of the kind of output
+	assert.NotContains(t, readFile(t, errors), "Slow query")
+	assert.Contains(t, readFile(t, errors), `"message":"Cannot find movie"`)
+	assert.Contains(t, readFile(t, errors), `"@version":"1"`)
+
+	assert.Contains(t, readFile(t, all), `"msg":"Slow query"`)
+	assert.Contains(t, readFile(t, all), `"msg":"Cannot find movie"`)
+	assert.NotContains(t, readFile(t, all), "@version")
+}
+
+func TestInvalidOutputOptions(t *testing.T) {
+	dir := tempDir(t)
+
+	for _, config := range []string{
+		filepath.Join(dir, "a.log") + "?level=verbose",
+		filepath.Join(dir, "a.log") + "?color=true",
+		"stderr?level=%zz",
+	} {
+		setOutputConfig(t, "LOGGER_OUTPUT", config)
+		_, err := newOutputs()
+		assert.Error(t, err, config)
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := tempDir(t)
+	setOutputConfig(t, "LOGGER_FILE_ROTATE_INTERVAL", "20ms")
+	setOutputConfig(t, "LOGGER_FILE_MAX_BACKUPS", 100)
+
+	files := func() int {
+		matches, err := filepath.Glob(filepath.Join(dir, "api*.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(matches)
+	}
+
+	w, stop := newFileWriter(filepath.Join(dir, "api.log"))
+	w.Write([]byte("first\n"))
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, files() > 1, "file rotated")
+
+	// Rotation stops with the writer
+	stop()
+	rotated := files()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, rotated, files())
+	stop()
+}
+
+func TestSetOutputs(t *testing.T) {
+	previous := activeOutputs
+	t.Cleanup(func() { activeOutputs = previous })
+
+	closed := []string{}
+	newOutput := func(name string) *output {
+		return &output{close: func() { closed = append(closed, name) }}
+	}
+
+	setOutputs([]*output{newOutput("a"), {}, newOutput("b")})
+	assert.Empty(t, closed)
+
+	setOutputs([]*output{newOutput("c")})
+	assert.Equal(t, []string{"a", "b"}, closed)
+}
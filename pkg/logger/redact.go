@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Redacted replaces sensitive values in log entries
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are the field key patterns redacted when LOGGER_REDACT_KEYS is not set
+const DefaultRedactKeys = "password,passwd,secret,token,authorization,api[_-]?key,email"
+
+// valueDetectors are the available detectors of sensitive values, enabled by name using LOGGER_REDACT_DETECTORS
+var valueDetectors = map[string]*regexp.Regexp{
+	"email":  regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	"jwt":    regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	// Only token-shaped credentials (16+ characters of the base64 and JWT charset) are
+	// redacted, so that text such as "basic auth disabled" is kept
+	"bearer": regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/\-]{16,}=*`),
+}
+
+// redactor removes sensitive data from fields and messages. Field keys matching
+// any key pattern get their whole value replaced, while string values are
+// scanned by the enabled value detectors.
+type redactor struct {
+	keys      *regexp.Regexp
+	detectors []*regexp.Regexp
+}
+
+var (
+	redactorMu     sync.RWMutex
+	activeRedactor = newRedactor(DefaultRedactKeys, allDetectors())
+)
+
+func allDetectors() []string {
+	names := []string{}
+	for name := range valueDetectors {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// newRedactor builds a redactor from a comma separated list of key patterns (regular
+// expressions matched case insensitive) and the names of the value detectors to use
+func newRedactor(keys string, detectors []string) *redactor {
+	r := &redactor{}
+
+	patterns := []string{}
+	for _, k := range strings.Split(keys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			patterns = append(patterns, k)
+		}
+	}
+
+	if len(patterns) > 0 {
+		keysRegexp, err := regexp.Compile(fmt.Sprintf("(?i)(%s)", strings.Join(patterns, "|")))
+		if err != nil {
+			keysRegexp = regexp.MustCompile(fmt.Sprintf("(?i)(%s)", strings.Replace(DefaultRedactKeys, ",", "|", -1)))
+		}
+		r.keys = keysRegexp
+	}
+
+	for _, name := range detectors {
+		if d, ok := valueDetectors[strings.ToLower(strings.TrimSpace(name))]; ok {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+
+	return r
+}
+
+// ReloadRedaction configures redaction from LOGGER_REDACT_KEYS (comma separated key
+// patterns) and LOGGER_REDACT_DETECTORS (comma separated list of "email", "jwt", "bearer").
+// Setting either one to "none" disables it.
+func ReloadRedaction() {
+	keys := DefaultRedactKeys
+	if viper.IsSet("LOGGER_REDACT_KEYS") {
+		keys = viper.GetString("LOGGER_REDACT_KEYS")
+	}
+
+	detectors := allDetectors()
+	if viper.IsSet("LOGGER_REDACT_DETECTORS") {
+		detectors = strings.Split(viper.GetString("LOGGER_REDACT_DETECTORS"), ",")
+	}
+
+	if strings.ToLower(keys) == "none" {
+		keys = ""
+	}
+
+	r := newRedactor(keys, detectors)
+
+	redactorMu.Lock()
+	activeRedactor = r
+	redactorMu.Unlock()
+}
+
+func currentRedactor() *redactor {
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+
+	return activeRedactor
+}
+
+// Redact returns a copy of fields with sensitive values replaced
+func Redact(fields LogFields) LogFields {
+	return LogFields(currentRedactor().fields(fields))
+}
+
+// RedactString replaces sensitive values found in s
+func RedactString(s string) string {
+	return currentRedactor().string(s)
+}
+
+func (r *redactor) string(s string) string {
+	for _, d := range r.detectors {
+		s = d.ReplaceAllString(s, Redacted)
+	}
+
+	return s
+}
+
+func (r *redactor) fields(fields map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if r.keys != nil && r.keys.MatchString(k) {
+			redacted[k] = Redacted
+			continue
+		}
+		redacted[k] = r.value(v)
+	}
+
+	return redacted
+}
+
+func (r *redactor) value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return r.string(t)
+	case error:
+		return r.string(t.Error())
+	case LogFields:
+		return LogFields(r.fields(t))
+	case logrus.Fields:
+		return logrus.Fields(r.fields(t))
+	case map[string]interface{}:
+		return r.fields(t)
+	case map[string]string:
+		m := make(map[string]interface{}, len(t))
+		for k, s := range t {
+			m[k] = s
+		}
+		return r.fields(m)
+	case []string:
+		s := make([]string, len(t))
+		for i, e := range t {
+			s[i] = r.string(e)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, e := range t {
+			s[i] = r.value(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// redactionHook redacts entries logged directly through logrus (e.g. using NewLogger) before
+// they reach any output, so it must be the first hook added. Entries written by the logging
+// functions are redacted before reaching their sink.
+type redactionHook struct{}
+
+// Levels returns all levels
+func (redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts entry message and fields in place (nested values are copied)
+func (redactionHook) Fire(entry *logrus.Entry) error {
+	r := currentRedactor()
+
+	entry.Message = r.string(entry.Message)
+	entry.Data = logrus.Fields(r.fields(entry.Data))
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxSampledKeys bounds the number of message keys tracked by the sampler.
+// Counters are reset when the limit is reached.
+const maxSampledKeys = 4096
+
+// samplingRule lets the first entries of every message key through on each interval,
+// and then only every Thereafter-th one (none if Thereafter is zero)
+type samplingRule struct {
+	First      int
+	Thereafter int
+	Interval   time.Duration
+}
+
+// sampleCounter counts the entries logged with a message key during the current interval
+type sampleCounter struct {
+	reset time.Time
+	count int
+}
+
+// sampler applies per level sampling rules, counting entries by level and message
+type sampler struct {
+	mu       sync.Mutex
+	rules    map[logrus.Level]samplingRule
+	counters map[string]*sampleCounter
+}
+
+// traceDeduper drops stack traces that were already logged within an interval
+type traceDeduper struct {
+	mu       sync.Mutex
+	interval time.Duration
+	seen     map[string]time.Time
+}
+
+var (
+	sampling = &sampler{rules: map[logrus.Level]samplingRule{}, counters: map[string]*sampleCounter{}}
+	traces   = &traceDeduper{seen: map[string]time.Time{}}
+)
+
+// allow reports whether an entry with the given level and message should be logged
+func (s *sampler) allow(level logrus.Level, msg string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.rules[level]
+	if !ok {
+		return true
+	}
+
+	key := level.String() + "|" + msg
+	c, ok := s.counters[key]
+	if !ok {
+		if len(s.counters) >= maxSampledKeys {
+			s.counters = map[string]*sampleCounter{}
+		}
+		c = &sampleCounter{}
+		s.counters[key] = c
+	}
+
+	if !now.Before(c.reset) {
+		c.reset = now.Add(rule.Interval)
+		c.count = 0
+	}
+
+	c.count++
+	if c.count <= rule.First {
+		return true
+	}
+
+	return rule.Thereafter > 0 && (c.count-rule.First)%rule.Thereafter == 0
+}
+
+// dedup replaces the "trace" field with its hash if the same trace was already
+// logged during the interval. It reports whether the trace was removed.
+func (d *traceDeduper) dedup(fields map[string]interface{}, now time.Time) bool {
+	trace, ok := fields["trace"].(string)
+	if !ok || trace == "" {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(trace))
+	hash := hex.EncodeToString(sum[:8])
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.interval <= 0 {
+		return false
+	}
+
+	fields["trace_hash"] = hash
+
+	if last, ok := d.seen[hash]; ok && now.Sub(last) < d.interval {
+		delete(fields, "trace")
+		return true
+	}
+
+	if len(d.seen) >= maxSampledKeys {
+		d.seen = map[string]time.Time{}
+	}
+	d.seen[hash] = now
+
+	return false
+}
+
+// parseSamplingRules parses rules like "debug=100:10:1s,info=1000:100"
+// (level=first:thereafter[:interval], the interval defaults to one second)
+func parseSamplingRules(config string) (map[logrus.Level]samplingRule, error) {
+	rules := map[logrus.Level]samplingRule{}
+
+	for _, pair := range strings.Split(config, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid sampling rule: %q", pair)
+		}
+
+		level, err := ParseLevel(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		values := strings.Split(strings.TrimSpace(parts[1]), ":")
+		if len(values) < 2 || len(values) > 3 {
+			return nil, fmt.Errorf("Invalid sampling rule: %q", pair)
+		}
+
+		rule := samplingRule{Interval: time.Second}
+		if rule.First, err = strconv.Atoi(values[0]); err != nil || rule.First < 0 {
+			return nil, fmt.Errorf("Invalid sampling rule: %q", pair)
+		}
+		if rule.Thereafter, err = strconv.Atoi(values[1]); err != nil || rule.Thereafter < 0 {
+			return nil, fmt.Errorf("Invalid sampling rule: %q", pair)
+		}
+		if len(values) == 3 {
+			if rule.Interval, err = time.ParseDuration(values[2]); err != nil || rule.Interval <= 0 {
+				return nil, fmt.Errorf("Invalid sampling rule: %q", pair)
+			}
+		}
+
+		rules[level.logrus()] = rule
+	}
+
+	return rules, nil
+}
+
+// ReloadSampling configures sampling from LOGGER_SAMPLING (per level rules, e.g.
+// "debug=100:10:1s": the first 100 debug entries with the same message are logged every
+// second, then one in 10) and trace deduplication from LOGGER_TRACE_DEDUP_INTERVAL
+// (identical stack traces are only logged once per interval, zero disables it).
+// Fatal entries are never sampled.
+func ReloadSampling() error {
+	rules, err := parseSamplingRules(viper.GetString("LOGGER_SAMPLING"))
+	if err != nil {
+		return err
+	}
+	delete(rules, logrus.FatalLevel)
+
+	sampling.mu.Lock()
+	sampling.rules = rules
+	sampling.counters = map[string]*sampleCounter{}
+	sampling.mu.Unlock()
+
+	traces.mu.Lock()
+	traces.interval = viper.GetDuration("LOGGER_TRACE_DEDUP_INTERVAL")
+	traces.seen = map[string]time.Time{}
+	traces.mu.Unlock()
+
+	return nil
+}
+
+// sample applies sampling and trace deduplication to an entry, reporting whether it
+// should be logged. Dropped entries and traces are counted as metrics.
+func sample(level logrus.Level, msg string, fields map[string]interface{}) bool {
+	now := time.Now()
+	lvl := string(fromLogrusLevel(level))
+
+	if !sampling.allow(level, msg, now) {
+		metrics.LogEntryDropped(lvl)
+		return false
+	}
+
+	if traces.dedup(fields, now) {
+		metrics.LogTraceDeduplicated(lvl)
+	}
+
+	return true
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSamplingRules(t *testing.T) {
+	rules, err := parseSamplingRules("debug=100:10:2s, info=5:0")
+	assert.Nil(t, err)
+	assert.Equal(t, samplingRule{First: 100, Thereafter: 10, Interval: 2 * time.Second}, rules[logrus.DebugLevel])
+	assert.Equal(t, samplingRule{First: 5, Thereafter: 0, Interval: time.Second}, rules[logrus.InfoLevel])
+
+	for _, invalid := range []string{"debug", "debug=1", "verbose=1:1", "debug=a:1", "debug=1:1:0s"} {
+		_, err := parseSamplingRules(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{
+		rules:    map[logrus.Level]samplingRule{logrus.DebugLevel: {First: 2, Thereafter: 3, Interval: time.Second}},
+		counters: map[string]*sampleCounter{},
+	}
+	now := time.Now()
+
+	allowed := []bool{}
+	for i := 0; i < 8; i++ {
+		allowed = append(allowed, s.allow(logrus.DebugLevel, "CYPHER_QUERY", now))
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true}, allowed)
+
+	// keys and levels are counted separately, and levels without rules are not sampled
+	assert.True(t, s.allow(logrus.DebugLevel, "OTHER", now))
+	for i := 0; i < 10; i++ {
+		assert.True(t, s.allow(logrus.InfoLevel, "CYPHER_QUERY", now))
+	}
+
+	// counters are reset every interval
+	assert.True(t, s.allow(logrus.DebugLevel, "CYPHER_QUERY", now.Add(time.Second)))
+}
+
+func TestTraceDedup(t *testing.T) {
+	d := &traceDeduper{interval: time.Minute, seen: map[string]time.Time{}}
+	now := time.Now()
+
+	first := map[string]interface{}{"trace": "stack"}
+	assert.False(t, d.dedup(first, now))
+	assert.Equal(t, "stack", first["trace"])
+	assert.NotEmpty(t, first["trace_hash"])
+
+	second := map[string]interface{}{"trace": "stack"}
+	assert.True(t, d.dedup(second, now.Add(time.Second)))
+	assert.NotContains(t, second, "trace")
+	assert.Equal(t, first["trace_hash"], second["trace_hash"])
+
+	third := map[string]interface{}{"trace": "stack"}
+	assert.False(t, d.dedup(third, now.Add(2*time.Minute)))
+	assert.Equal(t, "stack", third["trace"])
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is a logging backend. It receives entries that already passed level
+// filtering, sampling and redaction, with arguments parsed into a message and fields.
+type Sink interface {
+	Log(level LogLevel, msg string, fields LogFields)
+}
+
+var (
+	sinkMu      sync.RWMutex
+	defaultSink Sink
+)
+
+// SetSink replaces the backend used by the package level functions and by any
+// Entry without its own sink. A nil sink restores the logrus logger configured
+// by InitializeLogger.
+func SetSink(s Sink) {
+	sinkMu.Lock()
+	defaultSink = s
+	sinkMu.Unlock()
+}
+
+// currentSink returns s, falling back to the default sink
+func currentSink(s Sink) Sink {
+	if s != nil {
+		return s
+	}
+
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+
+	if defaultSink != nil {
+		return defaultSink
+	}
+
+	return &LogrusSink{Logger: Logger}
+}
+
+// LogrusSink writes entries using a logrus logger
+type LogrusSink struct {
+	Logger *logrus.Logger
+}
+
+// NewLogrusSink returns a sink writing to l
+func NewLogrusSink(l *logrus.Logger) *LogrusSink {
+	return &LogrusSink{Logger: l}
+}
+
+// Log writes an entry. Fatal entries are written without exiting.
+func (s *LogrusSink) Log(level LogLevel, msg string, fields LogFields) {
+	s.Logger.WithFields(logrus.Fields(fields)).Log(level.logrus(), msg)
+}
+
+// SlogLogger is the subset of *slog.Logger (log/slog) used by SlogSink, so a
+// slog logger can be used as backend without requiring a newer Go version here
+type SlogLogger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// SlogSink writes entries using a slog compatible logger. Fields are passed as
+// key-value pairs sorted by key, and fatal entries are written as errors with
+// a "fatal" attribute.
+type SlogSink struct {
+	Logger SlogLogger
+}
+
+// NewSlogSink returns a sink writing to l (e.g. slog.Default())
+func NewSlogSink(l SlogLogger) *SlogSink {
+	return &SlogSink{Logger: l}
+}
+
+// Log writes an entry
+func (s *SlogSink) Log(level LogLevel, msg string, fields LogFields) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, len(keys)*2+2)
+	for _, k := range keys {
+		args = append(args, k, fields[k])
+	}
+
+	ctx := context.Background()
+	switch level {
+	case LevelDebug:
+		s.Logger.DebugContext(ctx, msg, args...)
+	case LevelInfo:
+		s.Logger.InfoContext(ctx, msg, args...)
+	case LevelWarning:
+		s.Logger.WarnContext(ctx, msg, args...)
+	case LevelFatal:
+		s.Logger.ErrorContext(ctx, msg, append(args, "fatal", true)...)
+	default:
+		s.Logger.ErrorContext(ctx, msg, args...)
+	}
+}
+
+// CapturedEntry is an entry recorded by CaptureSink
+type CapturedEntry struct {
+	Level   LogLevel
+	Message string
+	Fields  LogFields
+}
+
+// CaptureSink records entries in memory, so tests can assert what was logged
+//   e.g.  capture := logger.NewCaptureSink()
+//         repo := &repository.Neo4jRepository{Logger: logger.New(capture)}
+type CaptureSink struct {
+	mu      sync.Mutex
+	entries []CapturedEntry
+}
+
+// NewCaptureSink returns an empty capture sink
+func NewCaptureSink() *CaptureSink {
+	return &CaptureSink{}
+}
+
+// Log records an entry
+func (c *CaptureSink) Log(level LogLevel, msg string, fields LogFields) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = append(c.entries, CapturedEntry{Level: level, Message: msg, Fields: fields})
+}
+
+// Entries returns the recorded entries, oldest first
+func (c *CaptureSink) Entries() []CapturedEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]CapturedEntry{}, c.entries...)
+}
+
+// Find returns the recorded entries with the given message
+func (c *CaptureSink) Find(msg string) []CapturedEntry {
+	found := []CapturedEntry{}
+	for _, e := range c.Entries() {
+		if e.Message == msg {
+			found = append(found, e)
+		}
+	}
+
+	return found
+}
+
+// Reset removes all recorded entries
+func (c *CaptureSink) Reset() {
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
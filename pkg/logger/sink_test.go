@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSlog records calls the way *slog.Logger would receive them
+type fakeSlog struct {
+	calls []string
+	args  [][]interface{}
+}
+
+func (f *fakeSlog) record(level string, msg string, args []interface{}) {
+	f.calls = append(f.calls, level+" "+msg)
+	f.args = append(f.args, args)
+}
+
+func (f *fakeSlog) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	f.record("DEBUG", msg, args)
+}
+
+func (f *fakeSlog) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	f.record("INFO", msg, args)
+}
+
+func (f *fakeSlog) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	f.record("WARN", msg, args)
+}
+
+func (f *fakeSlog) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	f.record("ERROR", msg, args)
+}
+
+func TestCaptureSink(t *testing.T) {
+	captureLogger(t)
+	assert.Nil(t, SetLevel("info"))
+
+	capture := NewCaptureSink()
+	log := New(capture)
+
+	ctx := NewContext(context.Background(), LogFields{FieldRequestID: "abc"})
+	log.WithContext(ctx).Debug("filtered out")
+	log.WithContext(ctx).Info("Movie found", LogFields{"uuid": "1", "password": "hunter2"})
+	log.Error("Cannot find movie", errors.New("boom"))
+
+	entries := capture.Entries()
+	assert.Len(t, entries, 2)
+
+	assert.Equal(t, LevelInfo, entries[0].Level)
+	assert.Equal(t, "Movie found", entries[0].Message)
+	assert.Equal(t, "abc", entries[0].Fields[FieldRequestID])
+	assert.Equal(t, "1", entries[0].Fields["uuid"])
+	assert.Equal(t, Redacted, entries[0].Fields["password"])
+
+	found := capture.Find("Cannot find movie")
+	assert.Len(t, found, 1)
+	assert.Equal(t, LevelError, found[0].Level)
+	assert.Equal(t, "boom", found[0].Fields["error_msg"])
+
+	capture.Reset()
+	assert.Empty(t, capture.Entries())
+}
+
+func TestDefaultSink(t *testing.T) {
+	buf := captureLogger(t)
+	assert.Nil(t, SetLevel("info"))
+
+	capture := NewCaptureSink()
+	SetSink(capture)
+	Info("To the capture sink")
+	SetSink(nil)
+	Info("To logrus")
+
+	// a nil entry logs to the default sink
+	var log *Entry
+	log.WithContext(context.Background()).Info("Nil entry")
+
+	assert.Len(t, capture.Find("To the capture sink"), 1)
+	assert.Empty(t, capture.Find("To logrus"))
+	assert.NotContains(t, buf.String(), "To the capture sink")
+	assert.Contains(t, buf.String(), "To logrus")
+	assert.Contains(t, buf.String(), "Nil entry")
+}
+
+func TestLogrusSink(t *testing.T) {
+	captureLogger(t)
+	assert.Nil(t, SetLevel("warning"))
+
+	buf := &bytes.Buffer{}
+	l := logrus.New()
+	l.SetOutput(buf)
+	l.SetFormatter(&logrus.JSONFormatter{})
+
+	New(NewLogrusSink(l)).Warning("Slow", LogFields{"duration_ms": 12})
+
+	entry := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "Slow", entry["msg"])
+	assert.Equal(t, float64(12), entry["duration_ms"])
+}
+
+func TestSlogSink(t *testing.T) {
+	captureLogger(t)
+	assert.Nil(t, SetLevel("debug"))
+
+	fake := &fakeSlog{}
+	log := New(NewSlogSink(fake))
+
+	log.Debug("one", LogFields{"b": 2, "a": 1})
+	log.Warning("two")
+	log.Error("three")
+
+	assert.Equal(t, []string{"DEBUG one", "WARN two", "ERROR three"}, fake.calls)
+	assert.Equal(t, []interface{}{"a", 1, "b", 2, "prefix", "main"}, fake.args[0])
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// maxOperations caps the number of operation names recorded when they are not known in
+// advance, as clients choose them
+const maxOperations = 100
+
+// otherOperation labels operations whose name is not recorded
+const otherOperation = "other"
+
+// Tracer is a gqlgen extension that records operation and resolver durations
+type Tracer struct {
+	// known, when not nil, holds the only operation names recorded
+	known map[string]bool
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = &Tracer{}
+
+// NewTracer creates a tracer labelling operations with their name when it is one of
+// operations (e.g. the safelisted ones), "other" otherwise. When operations is nil, the
+// first maxOperations names are recorded.
+func NewTracer(operations []string) *Tracer {
+	t := &Tracer{seen: map[string]bool{}}
+	if operations != nil {
+		t.known = map[string]bool{}
+		for _, name := range operations {
+			t.known[name] = true
+		}
+	}
+
+	return t
+}
+
+// ExtensionName returns the extension name
+func (t *Tracer) ExtensionName() string {
+	return "Metrics"
+}
+
+// Validate is a no-op, the tracer works with any schema
+func (t *Tracer) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse records the duration of an operation, from the moment it was received
+func (t *Tracer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+
+	if !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+
+	rc := graphql.GetOperationContext(ctx)
+	failed := resp == nil || len(resp.Errors) > 0
+	operationDuration.WithLabelValues(t.operation(rc.OperationName), status(failed)).Observe(time.Since(rc.Stats.OperationStart).Seconds())
+
+	return resp
+}
+
+// operation returns the label value of the operation named name, bounding the number
+// of time series clients can create
+func (t *Tracer) operation(name string) string {
+	if name == "" {
+		return "anonymous"
+	}
+
+	if t.known != nil {
+		if t.known[name] {
+			return name
+		}
+		return otherOperation
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.seen[name] {
+		if len(t.seen) >= maxOperations {
+			return otherOperation
+		}
+		t.seen[name] = true
+	}
+
+	return name
+}
+
+// InterceptField records the duration of fields backed by a resolver method
+func (t *Tracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsMethod {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	res, err := next(ctx)
+	resolverDuration.WithLabelValues(fc.Object, fc.Field.Name, status(err != nil)).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
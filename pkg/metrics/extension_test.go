@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// execute runs an operation named name through tr, failing it if failed is set
+func execute(tr *Tracer, name string, failed bool) {
+	rc := &graphql.OperationContext{OperationName: name}
+	rc.Stats.OperationStart = time.Now()
+	ctx := graphql.WithOperationContext(context.Background(), rc)
+
+	tr.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+		if failed {
+			return &graphql.Response{Errors: gqlerror.List{gqlerror.Errorf("boom")}}
+		}
+		return &graphql.Response{}
+	})
+}
+
+// operations returns the operation names of the recorded operations
+func operations(t *testing.T) map[string]bool {
+	names := map[string]bool{}
+	for _, labels := range series(t, operationDuration) {
+		names[labels["operation"]] = true
+	}
+
+	return names
+}
+
+func TestTracerKnownOperations(t *testing.T) {
+	tr := NewTracer([]string{"TestMovies"})
+
+	execute(tr, "TestMovies", false)
+	execute(tr, "TestMovies", true)
+	execute(tr, "TestUnknown", false)
+	execute(tr, "", false)
+
+	recorded := operations(t)
+	assert.True(t, recorded["TestMovies"])
+	assert.True(t, recorded[otherOperation])
+	assert.True(t, recorded["anonymous"])
+	assert.False(t, recorded["TestUnknown"])
+
+	assert.Contains(t, series(t, operationDuration), map[string]string{"operation": "TestMovies", "status": StatusError})
+}
+
+func TestTracerBoundedOperations(t *testing.T) {
+	tr := NewTracer(nil)
+
+	for i := 0; i < maxOperations+10; i++ {
+		execute(tr, fmt.Sprintf("TestOperation%d", i), false)
+	}
+	// Names already recorded still are
+	execute(tr, "TestOperation0", false)
+
+	recorded := operations(t)
+	assert.True(t, recorded["TestOperation0"])
+	assert.True(t, recorded[fmt.Sprintf("TestOperation%d", maxOperations-1)])
+	assert.False(t, recorded[fmt.Sprintf("TestOperation%d", maxOperations)])
+	assert.True(t, recorded[otherOperation])
+	assert.Len(t, tr.seen, maxOperations)
+}
+
+func TestTracerResolvers(t *testing.T) {
+	tr := NewTracer(nil)
+
+	field := func(name string, method bool) context.Context {
+		return graphql.WithFieldContext(context.Background(), &graphql.FieldContext{
+			Object:   "TestQuery",
+			Field:    graphql.CollectedField{Field: &ast.Field{Name: name}},
+			IsMethod: method,
+		})
+	}
+
+	_, err := tr.InterceptField(field("movies", true), func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	assert.Error(t, err)
+	_, _ = tr.InterceptField(field("title", false), func(ctx context.Context) (interface{}, error) {
+		return "The Matrix", nil
+	})
+
+	resolvers := series(t, resolverDuration)
+	assert.Contains(t, resolvers, map[string]string{"object": "TestQuery", "field": "movies", "status": StatusError})
+	assert.NotContains(t, resolvers, map[string]string{"object": "TestQuery", "field": "title", "status": StatusOK})
+}
@@ -0,0 +1,198 @@
+// Package metrics exposes Prometheus collectors for GraphQL operations,
+// resolvers, Cypher queries and logging, plus the HTTP handler that serves them.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "goneo4jgql"
+
+const (
+	// StatusOK is used to label successful operations and queries
+	StatusOK = "ok"
+	// StatusError is used to label failed operations and queries
+	StatusError = "error"
+)
+
+var (
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of GraphQL operations by operation name and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	resolverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "resolver_duration_seconds",
+		Help:      "Duration of GraphQL field resolvers by object, field and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"object", "field", "status"})
+
+	cypherDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of Cypher queries by repository method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	cypherErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "query_errors_total",
+		Help:      "Number of failed Cypher queries by repository method.",
+	}, []string{"method"})
+
+	sessionsInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "sessions_in_use",
+		Help:      "Number of Neo4j sessions currently open.",
+	})
+
+	sessionAcquisition = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "session_acquisition_duration_seconds",
+		Help:      "Time spent acquiring a Neo4j session from the driver pool.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	sessionErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "session_errors_total",
+		Help:      "Number of Neo4j sessions that could not be acquired.",
+	})
+
+	poolMaxSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "neo4j",
+		Name:      "pool_max_size",
+		Help:      "Maximum number of connections the Neo4j driver pool may open.",
+	})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "repository_cache",
+		Name:      "requests_total",
+		Help:      "Number of repository cache lookups by repository method and result (hit or miss).",
+	}, []string{"method", "result"})
+
+	cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "repository_cache",
+		Name:      "entries",
+		Help:      "Number of results held by the repository cache.",
+	})
+
+	logDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "logger",
+		Name:      "dropped_entries_total",
+		Help:      "Number of log entries dropped by sampling, by level.",
+	}, []string{"level"})
+
+	logDeduplicated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "logger",
+		Name:      "deduplicated_traces_total",
+		Help:      "Number of stack traces omitted from log entries because they were already logged, by level.",
+	}, []string{"level"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		operationDuration,
+		resolverDuration,
+		cypherDuration,
+		cypherErrors,
+		sessionsInUse,
+		sessionAcquisition,
+		sessionErrors,
+		poolMaxSize,
+		cacheRequests,
+		cacheEntries,
+		logDropped,
+		logDeduplicated,
+	)
+}
+
+// Handler returns the HTTP handler serving all registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveCypherQuery records the duration of a Cypher query started at start,
+// and counts it as failed if err is not nil
+func ObserveCypherQuery(method string, start time.Time, err error) {
+	cypherDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		cypherErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveSessionAcquisition records the time spent acquiring a Neo4j session.
+// A successfully acquired session is counted as in use until SessionClosed is called.
+func ObserveSessionAcquisition(start time.Time, err error) {
+	sessionAcquisition.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		sessionErrors.Inc()
+		return
+	}
+
+	sessionsInUse.Inc()
+}
+
+// SessionClosed marks a Neo4j session as no longer in use
+func SessionClosed() {
+	sessionsInUse.Dec()
+}
+
+// SetPoolMaxSize records the configured Neo4j driver pool size
+func SetPoolMaxSize(size int) {
+	poolMaxSize.Set(float64(size))
+}
+
+// CacheLookup counts a repository cache lookup for method, as a hit or a miss
+func CacheLookup(method string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheRequests.WithLabelValues(method, result).Inc()
+}
+
+// SetCacheEntries records the number of results held by the repository cache
+func SetCacheEntries(n int) {
+	cacheEntries.Set(float64(n))
+}
+
+// LogEntryDropped counts a log entry dropped by sampling
+func LogEntryDropped(level string) {
+	logDropped.WithLabelValues(level).Inc()
+}
+
+// LogTraceDeduplicated counts a stack trace omitted from a log entry
+func LogTraceDeduplicated(level string) {
+	logDeduplicated.WithLabelValues(level).Inc()
+}
+
+func status(failed bool) string {
+	if failed {
+		return StatusError
+	}
+
+	return StatusOK
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// series returns the label sets of the time series of c
+func series(t *testing.T, c prometheus.Collector) []map[string]string {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var labels []map[string]string
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+
+		values := map[string]string{}
+		for _, pair := range metric.Label {
+			values[pair.GetName()] = pair.GetValue()
+		}
+		labels = append(labels, values)
+	}
+
+	return labels
+}
+
+func TestObserveCypherQuery(t *testing.T) {
+	durations := testutil.CollectAndCount(cypherDuration)
+
+	ObserveCypherQuery("TestFindMovies", time.Now(), nil)
+	ObserveCypherQuery("TestFindMovies", time.Now(), nil)
+	ObserveCypherQuery("TestFindMovies", time.Now(), errors.New("boom"))
+
+	assert.Equal(t, durations+1, testutil.CollectAndCount(cypherDuration))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cypherErrors.WithLabelValues("TestFindMovies")))
+
+	assert.Contains(t, series(t, cypherDuration), map[string]string{"method": "TestFindMovies"})
+}
+
+func TestObserveSessionAcquisition(t *testing.T) {
+	inUse := testutil.ToFloat64(sessionsInUse)
+	failed := testutil.ToFloat64(sessionErrors)
+
+	ObserveSessionAcquisition(time.Now(), nil)
+	ObserveSessionAcquisition(time.Now(), errors.New("pool exhausted"))
+	assert.Equal(t, inUse+1, testutil.ToFloat64(sessionsInUse))
+	assert.Equal(t, failed+1, testutil.ToFloat64(sessionErrors))
+
+	SessionClosed()
+	assert.Equal(t, inUse, testutil.ToFloat64(sessionsInUse))
+}
@@ -0,0 +1,119 @@
+// Package persisted restricts GraphQL operations to a safelist of approved operations,
+// which clients can send by hash as automatic persisted queries
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+const errNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// Safelist holds approved operations, indexed by the SHA-256 hash of their text.
+// It is the cache of the APQ extension, so clients can send approved operations
+// by hash, and an extension rejecting any other query text.
+type Safelist struct {
+	queries map[string]string
+	names   []string
+}
+
+var _ interface {
+	graphql.Cache
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = &Safelist{}
+
+// LoadSafelist reads the approved operations of the *.graphql files in dir, one
+// operation per file. Whitespace around operations is ignored.
+func LoadSafelist(dir string) (*Safelist, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no operations found in safelist directory %s", dir)
+	}
+
+	s := &Safelist{queries: map[string]string{}, names: []string{}}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read safelist operation: %w", err)
+		}
+
+		query := strings.TrimSpace(string(data))
+		doc, gqlErr := parser.ParseQuery(&ast.Source{Name: f, Input: query})
+		if gqlErr != nil {
+			return nil, fmt.Errorf("invalid safelist operation: %w", gqlErr)
+		}
+
+		s.queries[Hash(query)] = query
+		for _, op := range doc.Operations {
+			if op.Name != "" {
+				s.names = append(s.names, op.Name)
+			}
+		}
+	}
+	sort.Strings(s.names)
+
+	return s, nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of query, as sent by APQ clients
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Len returns the number of approved operations
+func (s *Safelist) Len() int {
+	return len(s.queries)
+}
+
+// OperationNames returns the names of the approved operations, sorted
+func (s *Safelist) OperationNames() []string {
+	return s.names
+}
+
+// Get returns the approved operation with hash key
+func (s *Safelist) Get(ctx context.Context, key string) (interface{}, bool) {
+	query, ok := s.queries[key]
+	return query, ok
+}
+
+// Add is a no-op, operations sent by clients are never approved
+func (s *Safelist) Add(ctx context.Context, key string, value interface{}) {}
+
+// ExtensionName returns the extension name
+func (s *Safelist) ExtensionName() string {
+	return "Safelist"
+}
+
+// Validate is a no-op, the extension works with any schema
+func (s *Safelist) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters rejects operations that are not approved. It must run
+// after the APQ extension, which replaces hashes with the operations they identify.
+func (s *Safelist) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	if _, ok := s.queries[Hash(strings.TrimSpace(params.Query))]; ok {
+		return nil
+	}
+
+	err := gqlerror.Errorf("operation is not in the safelist")
+	errcode.Set(err, errNotAllowed)
+	return err
+}
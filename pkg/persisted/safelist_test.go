@@ -0,0 +1,79 @@
+package persisted
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+const moviesQuery = "{ movies { title } }"
+
+func newTestSafelist(t *testing.T, files map[string]string) (*Safelist, error) {
+	dir, err := ioutil.TempDir("", "safelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return LoadSafelist(dir)
+}
+
+func TestSafelist(t *testing.T) {
+	ctx := context.Background()
+	s, err := newTestSafelist(t, map[string]string{
+		"movies.graphql": moviesQuery + "\n",
+		"README.md":      "not an operation",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, s.Len())
+
+	query, ok := s.Get(ctx, Hash(moviesQuery))
+	assert.True(t, ok)
+	assert.Equal(t, moviesQuery, query)
+
+	// Operations sent by clients are not approved
+	s.Add(ctx, Hash("{ me { subject } }"), "{ me { subject } }")
+	_, ok = s.Get(ctx, Hash("{ me { subject } }"))
+	assert.False(t, ok)
+
+	assert.Nil(t, s.MutateOperationParameters(ctx, &graphql.RawParams{Query: "  " + moviesQuery}))
+
+	gqlErr := s.MutateOperationParameters(ctx, &graphql.RawParams{Query: "{ movies { tagline } }"})
+	if assert.NotNil(t, gqlErr) {
+		assert.Equal(t, "operation is not in the safelist", gqlErr.Message)
+		assert.Equal(t, "OPERATION_NOT_ALLOWED", gqlErr.Extensions["code"])
+	}
+}
+
+func TestLoadSafelistEmpty(t *testing.T) {
+	_, err := newTestSafelist(t, nil)
+	assert.NotNil(t, err)
+}
+
+func TestSafelistOperationNames(t *testing.T) {
+	s, err := newTestSafelist(t, map[string]string{
+		"movies.graphql": moviesQuery,
+		"movie.graphql":  `query Movie($uuid: String!) { movie(uuid: $uuid) { title } }`,
+		"me.graphql":     `query Me { me { subject } }`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Me", "Movie"}, s.OperationNames())
+
+	_, err = newTestSafelist(t, map[string]string{"invalid.graphql": "{ movies { title }"})
+	assert.NotNil(t, err)
+}
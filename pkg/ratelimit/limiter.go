@@ -0,0 +1,230 @@
+// Package ratelimit limits the rate of GraphQL requests of every client, with token
+// buckets keyed by API key, JWT subject or client IP
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errRateLimited = "RATE_LIMITED"
+
+// Config configures a Limiter
+type Config struct {
+	Limit
+	// ComplexityUnit, when positive, makes operations cost one token per
+	// ComplexityUnit of complexity (rounded up) instead of a single token
+	ComplexityUnit int
+	// IPLimit configures the buckets of client addresses, taken from before authentication
+	// and by anonymous clients. It defaults to Limit.
+	IPLimit Limit
+	// TrustedProxies is the number of proxies in front of the API appending the address
+	// of their client to X-Forwarded-For. Client addresses are taken from the one
+	// appended by the first of them, that is the TrustedProxies-th from the right: entries
+	// on its left are set by clients themselves. Zero ignores X-Forwarded-For.
+	TrustedProxies int
+}
+
+type ctxKey struct{}
+
+// client is the bucket requests are counted against, kept in their context
+type client struct {
+	key   string
+	limit Limit
+}
+
+// Limiter provides HTTP middlewares taking a token for every request, and a gqlgen
+// extension taking the rest of the operation cost once its complexity is known
+type Limiter struct {
+	config Config
+	store  Store
+	logger *logger.Entry
+	schema graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &Limiter{}
+
+// New creates a limiter keeping buckets in store, logging to l (the default logger if nil).
+// It returns an error unless the rate and burst are positive, as empty buckets would let
+// every request through.
+func New(c Config, store Store, l *logger.Entry) (*Limiter, error) {
+	if c.IPLimit == (Limit{}) {
+		c.IPLimit = c.Limit
+	}
+
+	for _, limit := range []Limit{c.Limit, c.IPLimit} {
+		if limit.Rate <= 0 {
+			return nil, errors.New("rate limit rate must be positive")
+		}
+		if limit.Burst <= 0 {
+			return nil, errors.New("rate limit burst must be positive")
+		}
+	}
+
+	return &Limiter{config: c, store: store, logger: l}, nil
+}
+
+// IPMiddleware rejects requests from addresses that ran out of tokens with a 429 status.
+// It must run before authentication, so failed authentications (e.g. guessed API keys)
+// are limited too.
+func (l *Limiter) IPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.serve(w, r, next, client{key: "ip:" + l.clientIP(r), limit: l.config.IPLimit})
+	})
+}
+
+// Middleware rejects requests from clients that ran out of tokens with a 429 status.
+// It must run after authentication, so authenticated clients are told apart. Anonymous
+// requests already counted by IPMiddleware are not counted again.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.FromContext(r.Context())
+		if p == nil {
+			if _, ok := r.Context().Value(ctxKey{}).(client); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			l.serve(w, r, next, client{key: "ip:" + l.clientIP(r), limit: l.config.IPLimit})
+			return
+		}
+
+		// API keys have "apikey:" subjects
+		l.serve(w, r, next, client{key: "user:" + p.Subject, limit: l.config.Limit})
+	})
+}
+
+// serve takes a token from the bucket of c, serving r with next if there was one left
+func (l *Limiter) serve(w http.ResponseWriter, r *http.Request, next http.Handler, c client) {
+	ctx := context.WithValue(r.Context(), ctxKey{}, c)
+
+	if wait := l.take(ctx, c, 1); wait > 0 {
+		err := limitError(wait)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(err.Extensions["retryAfter"].(int)))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(graphql.Response{Errors: gqlerror.List{err}})
+		return
+	}
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// ExtensionName returns the extension name
+func (l *Limiter) ExtensionName() string {
+	return "RateLimit"
+}
+
+// Validate keeps the schema, used to compute operation complexity
+func (l *Limiter) Validate(schema graphql.ExecutableSchema) error {
+	l.schema = schema
+	return nil
+}
+
+// MutateOperationContext takes the tokens an operation costs on top of the one taken
+// by the middleware, returning an error with a retryAfter extension when the client
+// runs out of them
+func (l *Limiter) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	cl, ok := ctx.Value(ctxKey{}).(client)
+	if !ok || l.config.ComplexityUnit <= 0 {
+		return nil
+	}
+
+	// Complexity is computed by the complexity limit extension, when enabled
+	var c int
+	if stats := extension.GetComplexityStats(ctx); stats != nil {
+		c = stats.Complexity
+	} else {
+		c = complexity.Calculate(l.schema, rc.Operation, rc.Variables)
+	}
+
+	cost := (c+l.config.ComplexityUnit-1)/l.config.ComplexityUnit - 1
+	if cost <= 0 {
+		return nil
+	}
+
+	if wait := l.take(ctx, cl, cost); wait > 0 {
+		return limitError(wait)
+	}
+
+	return nil
+}
+
+// take takes cost tokens from the bucket of c, returning how long to wait when there
+// are not enough. Requests are let through when the store fails.
+func (l *Limiter) take(ctx context.Context, c client, cost int) time.Duration {
+	wait, err := l.store.Take(ctx, c.key, cost, c.limit)
+	if err != nil {
+		l.logger.WithContext(ctx).Error("Cannot take rate limit tokens", err)
+		return 0
+	}
+
+	if wait > 0 {
+		l.logger.WithContext(ctx).Info("Rate limit exceeded", logger.LogFields{
+			"client": c.key,
+			"cost":   cost,
+			"wait":   wait.String(),
+		})
+	}
+
+	return wait
+}
+
+// clientIP returns the address of the client of r, taken from X-Forwarded-For when
+// there are trusted proxies
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.config.TrustedProxies > 0 {
+		var forwarded []string
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, address := range strings.Split(value, ",") {
+				if address = strings.TrimSpace(address); address != "" {
+					forwarded = append(forwarded, address)
+				}
+			}
+		}
+
+		// With fewer entries than proxies, every entry was appended by a trusted proxy
+		if len(forwarded) > 0 {
+			i := len(forwarded) - l.config.TrustedProxies
+			if i < 0 {
+				i = 0
+			}
+			return forwarded[i]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return host
+}
+
+// limitError returns the error telling a client to retry in wait, in whole seconds
+func limitError(wait time.Duration) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: "rate limit exceeded",
+		Extensions: map[string]interface{}{
+			"code":       errRateLimited,
+			"retryAfter": int(math.Ceil(wait.Seconds())),
+		},
+	}
+}
@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charlysan/goneo4jgql/pkg/auth"
+	"github.com/charlysan/goneo4jgql/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(now *time.Time) *MemoryStore {
+	s := NewMemoryStore()
+	s.now = func() time.Time { return *now }
+
+	return s
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestStore(&now)
+	limit := Limit{Rate: 2, Burst: 4}
+
+	wait, err := s.Take(ctx, "a", 3, limit)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// 1 token left, 2 are missing
+	wait, _ = s.Take(ctx, "a", 3, limit)
+	assert.Equal(t, time.Second, wait)
+
+	// Buckets are independent
+	wait, _ = s.Take(ctx, "b", 4, limit)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// Refilled at 2 tokens per second
+	now = now.Add(time.Second)
+	wait, _ = s.Take(ctx, "a", 3, limit)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// Costs are capped to the burst
+	now = now.Add(10 * time.Second)
+	wait, _ = s.Take(ctx, "a", 100, limit)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, _ = s.Take(ctx, "a", 100, limit)
+	assert.Equal(t, 2*time.Second, wait)
+
+	// Full buckets are forgotten
+	now = now.Add(sweepInterval)
+	_, _ = s.Take(ctx, "c", 1, limit)
+	assert.Len(t, s.buckets, 1)
+}
+
+func TestMemoryStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestStore(&now)
+	users := Limit{Rate: 0.1, Burst: 60}
+	addresses := Limit{Rate: 10, Burst: 5}
+
+	wait, _ := s.Take(ctx, "user:neo", 60, users)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// A sweep run for an address does not forget the drained user bucket, which would
+	// be full when next used
+	now = now.Add(sweepInterval)
+	wait, _ = s.Take(ctx, "ip:192.168.1.1", 1, addresses)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Len(t, s.buckets, 2)
+
+	// 6 tokens were refilled in a minute
+	wait, _ = s.Take(ctx, "user:neo", 7, users)
+	assert.Equal(t, 10*time.Second, wait)
+
+	// Buckets are forgotten once full according to their own limit
+	now = now.Add(10 * sweepInterval)
+	_, _ = s.Take(ctx, "ip:192.168.1.2", 1, addresses)
+	assert.Len(t, s.buckets, 1)
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Now()
+	l, err := New(Config{Limit: Limit{Rate: 1, Burst: 2}, TrustedProxies: 1}, newTestStore(&now), logger.New(logger.NewCaptureSink()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key string
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Context().Value(ctxKey{}).(client).key
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		key = ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, serve(req).Code)
+		assert.Equal(t, "ip:10.0.0.1", key)
+	}
+
+	rec := serve(req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "", key)
+
+	var body struct {
+		Errors []struct {
+			Message    string
+			Extensions map[string]interface{}
+		}
+	}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	if assert.Len(t, body.Errors, 1) {
+		assert.Equal(t, "rate limit exceeded", body.Errors[0].Message)
+		assert.Equal(t, map[string]interface{}{"code": "RATE_LIMITED", "retryAfter": float64(1)}, body.Errors[0].Extensions)
+	}
+
+	// Authenticated clients and forwarded addresses have their own buckets
+	user := req.WithContext(auth.NewContext(req.Context(), &auth.Principal{Subject: "apikey:0123"}))
+	assert.Equal(t, http.StatusOK, serve(user).Code)
+	assert.Equal(t, "user:apikey:0123", key)
+
+	forwarded := httptest.NewRequest(http.MethodPost, "/movies", nil)
+	forwarded.RemoteAddr = req.RemoteAddr
+	forwarded.Header.Set("X-Forwarded-For", "192.168.1.1, 172.16.0.1")
+	assert.Equal(t, http.StatusOK, serve(forwarded).Code)
+	assert.Equal(t, "ip:172.16.0.1", key)
+}
+
+func TestIPMiddleware(t *testing.T) {
+	now := time.Now()
+	l, err := New(Config{
+		Limit:   Limit{Rate: 1, Burst: 10},
+		IPLimit: Limit{Rate: 1, Burst: 3},
+	}, newTestStore(&now), logger.New(logger.NewCaptureSink()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests with a token are authenticated, the others are rejected
+	var keys []string
+	handler := l.IPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Context().Value(ctxKey{}).(client).key)
+		})).ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Subject: "alice"})))
+	}))
+
+	serve := func(header string) int {
+		r := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Failed authentications take tokens from the address bucket
+	assert.Equal(t, http.StatusUnauthorized, serve(""))
+	assert.Equal(t, http.StatusUnauthorized, serve(""))
+	assert.Equal(t, http.StatusOK, serve("Bearer token"))
+	assert.Equal(t, []string{"user:alice"}, keys)
+
+	assert.Equal(t, http.StatusTooManyRequests, serve(""))
+	assert.Equal(t, http.StatusTooManyRequests, serve("Bearer token"))
+
+	// Anonymous requests are counted once
+	anonymous := l.IPMiddleware(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		r.RemoteAddr = "10.0.0.2:1234"
+		rec := httptest.NewRecorder()
+		anonymous.ServeHTTP(rec, r)
+		assert.Equal(t, code, rec.Code, "request %d", i)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for _, test := range []struct {
+		proxies   int
+		forwarded []string
+		expected  string
+	}{
+		{0, []string{"192.168.1.1"}, "10.0.0.1"},
+		{1, nil, "10.0.0.1"},
+		{1, []string{"192.168.1.1"}, "192.168.1.1"},
+		// The leftmost entries are chosen by the client
+		{1, []string{"1.2.3.4, 192.168.1.1"}, "192.168.1.1"},
+		{2, []string{"1.2.3.4, 192.168.1.1", "172.16.0.1"}, "192.168.1.1"},
+		{3, []string{"192.168.1.1, 172.16.0.1"}, "192.168.1.1"},
+	} {
+		l, err := New(Config{Limit: Limit{Rate: 1, Burst: 1}, TrustedProxies: test.proxies}, NewMemoryStore(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/movies", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		for _, value := range test.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+
+		assert.Equal(t, test.expected, l.clientIP(r), "%d proxies, %v", test.proxies, test.forwarded)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Limit: Limit{Rate: 1, Burst: 0}}, NewMemoryStore(), nil)
+	assert.Error(t, err)
+
+	_, err = New(Config{Limit: Limit{Rate: 0, Burst: 10}}, NewMemoryStore(), nil)
+	assert.Error(t, err)
+
+	_, err = New(Config{Limit: Limit{Rate: 0.5, Burst: 1}}, NewMemoryStore(), nil)
+	assert.NoError(t, err)
+}
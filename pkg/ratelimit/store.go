@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit configures token buckets: they hold up to Burst tokens, refilled at Rate
+// tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Store keeps the token buckets of clients. Implementations sharing buckets between
+// instances (e.g. Redis) can replace the in-process MemoryStore.
+type Store interface {
+	// Take removes cost tokens, capped to the burst, from the bucket of key. When there
+	// are not enough tokens, none are taken and the time until there will be is returned.
+	Take(ctx context.Context, key string, cost int, limit Limit) (time.Duration, error)
+}
+
+// sweepInterval is how often MemoryStore forgets full buckets
+const sweepInterval = time.Minute
+
+// bucket holds the tokens of a client, with the limit it was last taken from, as
+// clients of a store can be limited differently (e.g. users and addresses)
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore keeps token buckets in memory
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Take removes cost tokens from the bucket of key, refilled since its last use
+func (s *MemoryStore) Take(ctx context.Context, key string, cost int, limit Limit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = refill(b, now, limit)
+	b.updated = now
+	b.limit = limit
+
+	need := math.Min(float64(cost), float64(limit.Burst))
+	if b.tokens >= need {
+		b.tokens -= need
+		return 0, nil
+	}
+
+	return time.Duration((need - b.tokens) / limit.Rate * float64(time.Second)), nil
+}
+
+// sweep forgets buckets that are full again (according to their own limit), as new
+// buckets are, so idle clients do not grow the store forever
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < sweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		if refill(b, now, b.limit) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// refill returns the tokens of b at now
+func refill(b *bucket, now time.Time, limit Limit) float64 {
+	tokens := b.tokens + now.Sub(b.updated).Seconds()*limit.Rate
+	return math.Min(tokens, float64(limit.Burst))
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel/api/key"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/plugin/othttp"
+)
+
+// Middleware starts a span for every incoming HTTP request, continuing any trace
+// found in the W3C traceparent header. Websocket upgrades are skipped, as the span
+// would otherwise last as long as the connection does.
+func Middleware(next http.Handler) http.Handler {
+	return othttp.NewHandler(next, "http",
+		othttp.WithFilter(func(r *http.Request) bool {
+			return r.Header.Get("Upgrade") == ""
+		}),
+		othttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		}),
+	)
+}
+
+// Tracer is a gqlgen extension that starts a span for every operation and resolver
+type Tracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = Tracer{}
+
+// ExtensionName returns the extension name
+func (Tracer) ExtensionName() string {
+	return "Tracing"
+}
+
+// Validate is a no-op, the tracer works with any schema
+func (Tracer) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation wraps the operation execution in a span
+func (Tracer) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	rc := graphql.GetOperationContext(ctx)
+
+	name := rc.OperationName
+	if name == "" {
+		name = "anonymous"
+	}
+
+	opType := "unknown"
+	if rc.Operation != nil {
+		opType = string(rc.Operation.Operation)
+	}
+
+	ctx, span := StartSpan(ctx, fmt.Sprintf("graphql.%s %s", opType, name),
+		key.String("graphql.operation.name", name),
+		key.String("graphql.operation.type", opType),
+	)
+
+	handler := next(ctx)
+
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+
+		// subscriptions produce responses until the handler returns nil
+		if resp != nil && rc.Operation != nil && rc.Operation.Operation == ast.Subscription {
+			return resp
+		}
+
+		if resp != nil && len(resp.Errors) > 0 {
+			EndSpan(ctx, span, resp.Errors)
+		} else {
+			EndSpan(ctx, span, nil)
+		}
+
+		return resp
+	}
+}
+
+// InterceptField wraps fields backed by a resolver method in a span
+func (Tracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsMethod {
+		return next(ctx)
+	}
+
+	var span trace.Span
+	ctx, span = StartSpan(ctx, fmt.Sprintf("graphql.resolve %s.%s", fc.Object, fc.Field.Name),
+		key.String("graphql.field.object", fc.Object),
+		key.String("graphql.field.name", fc.Field.Name),
+		key.String("graphql.field.path", fc.Path().String()),
+	)
+
+	res, err := next(ctx)
+	EndSpan(ctx, span, err)
+
+	return res, err
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/api/core"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	path, shutdown := initFileTracing(t)
+
+	var spanContext core.SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spanContext = trace.SpanFromContext(r.Context()).SpanContext()
+	}))
+
+	// The trace of the W3C traceparent header is continued
+	req := httptest.NewRequest(http.MethodPost, "/movies", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID.String())
+
+	// Requests without one start a new trace
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/movies", nil))
+	assert.True(t, spanContext.IsValid())
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID.String())
+
+	// Websocket upgrades are not traced
+	req = httptest.NewRequest(http.MethodGet, "/movies", nil)
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, spanContext.IsValid())
+
+	shutdown()
+
+	spans := readSpans(t, path)
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "POST /movies", spans[0].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID)
+		assert.Equal(t, "GET /movies", spans[1].Name)
+		assert.Equal(t, "0000000000000000", spans[1].ParentSpanID)
+	}
+}
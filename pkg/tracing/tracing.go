@@ -0,0 +1,164 @@
+// Package tracing provides OpenTelemetry tracing for the whole request path:
+// HTTP requests, GraphQL operations and resolvers, service calls and Cypher queries.
+//
+// The exporter is chosen using env var TRACING_EXPORTER:
+//
+//	"none"   (default, spans are not recorded)
+//	"stdout" (spans are written as JSON to stdout)
+//	"file"   (spans are written as JSON to TRACING_FILE)
+//	"otlp"   (spans are sent to an OpenTelemetry collector at TRACING_OTLP_ENDPOINT)
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/api/core"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/key"
+	"go.opentelemetry.io/otel/api/propagation"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/trace/stdout"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/codes"
+)
+
+// Exporter type used for specifying where spans are sent
+type Exporter string
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone Exporter = "none"
+	// ExporterStdout writes spans to stdout
+	ExporterStdout Exporter = "stdout"
+	// ExporterFile writes spans to a file
+	ExporterFile Exporter = "file"
+	// ExporterOTLP sends spans to an OpenTelemetry collector
+	ExporterOTLP Exporter = "otlp"
+)
+
+const instrumentationName = "github.com/charlysan/goneo4jgql"
+
+// Init configures the global trace provider and W3C trace context propagation.
+// The returned function flushes pending spans and releases the exporter.
+func Init() (func(), error) {
+	tc := trace.TraceContext{}
+	global.SetPropagators(propagation.New(propagation.WithInjectors(tc), propagation.WithExtractors(tc)))
+
+	exporter := Exporter(strings.ToLower(viper.GetString("TRACING_EXPORTER")))
+	if exporter == "" || exporter == ExporterNone {
+		return func() {}, nil
+	}
+
+	var batcher export.SpanBatcher
+	var syncer export.SpanSyncer
+	closers := []func(){}
+
+	switch exporter {
+	case ExporterStdout, ExporterFile:
+		var w io.Writer = os.Stdout
+		if exporter == ExporterFile {
+			f, err := os.OpenFile(viper.GetString("TRACING_FILE"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			w = f
+			closers = append(closers, func() { f.Close() })
+		}
+
+		exp, err := stdout.NewExporter(stdout.Options{Writer: w})
+		if err != nil {
+			return nil, err
+		}
+		syncer = exp
+	case ExporterOTLP:
+		exp, err := otlp.NewExporter(otlp.WithInsecure(), otlp.WithAddress(viper.GetString("TRACING_OTLP_ENDPOINT")))
+		if err != nil {
+			return nil, err
+		}
+		batcher = exp
+		closers = append(closers, func() { exp.Stop() })
+	default:
+		return nil, fmt.Errorf("Invalid tracing exporter: %s", exporter)
+	}
+
+	provider, err := sdktrace.NewProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ProbabilitySampler(viper.GetFloat64("TRACING_SAMPLE_RATIO"))}),
+		sdktrace.WithResourceAttributes(key.String("service.name", viper.GetString("TRACING_SERVICE_NAME"))),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var processor sdktrace.SpanProcessor
+	if batcher != nil {
+		processor, err = sdktrace.NewBatchSpanProcessor(batcher)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		processor = sdktrace.NewSimpleSpanProcessor(syncer)
+	}
+	provider.RegisterSpanProcessor(processor)
+
+	global.SetTraceProvider(provider)
+
+	return func() {
+		// Unregistering the processor flushes any pending span
+		provider.UnregisterSpanProcessor(processor)
+		for _, c := range closers {
+			c()
+		}
+	}, nil
+}
+
+// StartSpan starts a new span as a child of any span found in ctx
+func StartSpan(ctx context.Context, name string, attrs ...core.KeyValue) (context.Context, trace.Span) {
+	return global.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends span, recording err (if any) and flagging the span as failed
+func EndSpan(ctx context.Context, span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(ctx, err, trace.WithErrorStatus(codes.Unknown))
+	}
+
+	span.End()
+}
+
+var (
+	cypherStrings    = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	cypherNumbers    = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	cypherWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeCypher replaces string and numeric literals in a Cypher query with "?"
+// and collapses whitespace, so that the query text can be safely attached to a span
+func SanitizeCypher(query string) string {
+	query = cypherStrings.ReplaceAllString(query, "?")
+	query = cypherNumbers.ReplaceAllString(query, "?")
+
+	return strings.TrimSpace(cypherWhitespace.ReplaceAllString(query, " "))
+}
+
+// StartQuerySpan starts a span for a Cypher query run by a repository method
+func StartQuerySpan(ctx context.Context, method string, query string) (context.Context, trace.Span) {
+	return StartSpan(ctx, fmt.Sprintf("neo4j.%s", method),
+		key.String("db.system", "neo4j"),
+		key.String("db.operation", method),
+		key.String("db.statement", SanitizeCypher(query)),
+	)
+}
+
+// EndQuerySpan ends a span started by StartQuerySpan, recording the number of rows returned
+func EndQuerySpan(ctx context.Context, span trace.Span, rows int, err error) {
+	span.SetAttributes(key.Int("db.rows", rows))
+	EndSpan(ctx, span, err)
+}
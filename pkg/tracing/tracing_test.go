@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// exportedSpan holds the fields of spans written by the stdout and file exporters
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	ParentSpanID string
+	Attributes   []struct {
+		Key   string
+		Value struct{ Type string }
+	}
+}
+
+// setExporter configures exporter, sampling every trace, and restores the default
+// (disabled) tracing when the test ends
+func setExporter(t *testing.T, exporter string, file string) {
+	viper.Set("TRACING_EXPORTER", exporter)
+	viper.Set("TRACING_FILE", file)
+	viper.Set("TRACING_SAMPLE_RATIO", 1)
+	viper.Set("TRACING_SERVICE_NAME", "goneo4jgql")
+	t.Cleanup(func() {
+		viper.Set("TRACING_EXPORTER", "")
+		viper.Set("TRACING_FILE", "")
+		viper.Set("TRACING_SAMPLE_RATIO", 0)
+		viper.Set("TRACING_SERVICE_NAME", "")
+		global.SetTraceProvider(trace.NoopProvider{})
+	})
+}
+
+// initFileTracing starts exporting spans to a temporary file, returning its path
+// and the function flushing spans
+func initFileTracing(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "spans.json")
+	setExporter(t, "file", path)
+
+	shutdown, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path, shutdown
+}
+
+// readSpans decodes the spans written, one JSON object per line, to path
+func readSpans(t *testing.T, path string) []exportedSpan {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	spans := []exportedSpan{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s exportedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, s)
+	}
+
+	return spans
+}
+
+func TestInitExporters(t *testing.T) {
+	// Tracing is disabled by default
+	setExporter(t, "", "")
+	shutdown, err := Init()
+	assert.Nil(t, err)
+	shutdown()
+	_, span := StartSpan(context.Background(), "disabled")
+	assert.False(t, span.SpanContext().IsValid())
+
+	setExporter(t, "zipkin", "")
+	_, err = Init()
+	assert.NotNil(t, err)
+
+	setExporter(t, "file", filepath.Join("does", "not", "exist", "spans.json"))
+	_, err = Init()
+	assert.NotNil(t, err)
+}
+
+func TestFileExporter(t *testing.T) {
+	path, shutdown := initFileTracing(t)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, query := StartQuerySpan(ctx, "FindMovies", "MATCH (m:Movie) WHERE m.title = 'The Matrix' RETURN m")
+	EndQuerySpan(ctx, query, 1, errors.New("timeout"))
+	EndSpan(ctx, parent, nil)
+	shutdown()
+
+	spans := readSpans(t, path)
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "neo4j.FindMovies", spans[0].Name)
+		assert.Equal(t, spans[1].SpanContext.SpanID, spans[0].ParentSpanID)
+		assert.Equal(t, spans[1].SpanContext.TraceID, spans[0].SpanContext.TraceID)
+		keys := []string{}
+		for _, a := range spans[0].Attributes {
+			keys = append(keys, a.Key)
+		}
+		assert.Subset(t, keys, []string{"db.system", "db.operation", "db.statement", "db.rows"})
+		assert.Equal(t, "parent", spans[1].Name)
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	setExporter(t, "stdout", "")
+	shutdown, err := Init()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := StartSpan(context.Background(), "stdout")
+	EndSpan(context.Background(), span, nil)
+	shutdown()
+	w.Close()
+
+	var s exportedSpan
+	assert.Nil(t, json.NewDecoder(r).Decode(&s))
+	assert.Equal(t, "stdout", s.Name)
+}
+
+func TestSanitizeCypher(t *testing.T) {
+	tests := []struct {
+		query     string
+		sanitized string
+	}{
+		{
+			query:     "MATCH (m:Movie) WHERE m.uuid = $uuid RETURN m",
+			sanitized: "MATCH (m:Movie) WHERE m.uuid = $uuid RETURN m",
+		},
+		{
+			query:     "MATCH (m:Movie) WHERE m.title = 'The Matrix' AND m.released > 1999 RETURN m LIMIT 10",
+			sanitized: "MATCH (m:Movie) WHERE m.title = ? AND m.released > ? RETURN m LIMIT ?",
+		},
+		{
+			query:     `MATCH (p:Person {name: "Keanu \"Neo\" Reeves", born: 1964.5}) RETURN p`,
+			sanitized: "MATCH (p:Person {name: ?, born: ?}) RETURN p",
+		},
+		{
+			query:     "MATCH (m:Movie) WHERE m.title = 'It\\'s' RETURN m",
+			sanitized: "MATCH (m:Movie) WHERE m.title = ? RETURN m",
+		},
+		{
+			query:     "\n\t\tMATCH (m:Movie)\n\t\tRETURN m.title AS title1\n\t",
+			sanitized: "MATCH (m:Movie) RETURN m.title AS title1",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.sanitized, SanitizeCypher(tt.query))
+	}
+}